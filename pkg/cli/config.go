@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"kwekker-worker/pkg/config"
+	"text/tabwriter"
+)
+
+func newConfigCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	command.AddCommand(&cobra.Command{
+		Use:   "print",
+		Short: "Print every setting with its effective value and where that value came from, with secrets redacted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// The configuration is not validated, so that invalid settings can be printed as well.
+			if _, err := config.LoadConfig(); err != nil {
+				return fmt.Errorf("unable to load configuration; is the .env file present and valid? %w", err)
+			}
+
+			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "KEY\tVALUE\tSOURCE")
+
+			for _, setting := range config.Settings() {
+				fmt.Fprintf(writer, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Source)
+			}
+
+			return writer.Flush()
+		},
+	})
+
+	return command
+}
@@ -0,0 +1,304 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"kwekker-worker/pkg/rabbitmq"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// deadLetterFilter selects the dead-lettered messages a dlq subcommand acts on.
+type deadLetterFilter struct {
+	reason     string
+	routingKey string
+	since      string
+	until      string
+	limit      int
+
+	sinceTime time.Time
+	untilTime time.Time
+}
+
+func (f *deadLetterFilter) addFlags(command *cobra.Command) {
+	command.Flags().StringVar(&f.reason, "reason", "", `only messages rejected for this reason, e.g. "validation failed"`)
+	command.Flags().StringVar(&f.routingKey, "routing-key", "", "only messages originally published with this routing key")
+	command.Flags().StringVar(&f.since, "since", "", "only messages rejected after this time, given as RFC 3339 or as a duration ago, e.g. 2h")
+	command.Flags().StringVar(&f.until, "until", "", "only messages rejected before this time, given like --since")
+	command.Flags().IntVar(&f.limit, "limit", 1000, "number of messages to look at at most, matching or not")
+}
+
+// resolve parses the times of the filter relative to now.
+func (f *deadLetterFilter) resolve(now time.Time) error {
+	var err error
+
+	if f.sinceTime, err = parseTimeFlag(f.since, now); err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	if f.untilTime, err = parseTimeFlag(f.until, now); err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+
+	if f.limit < 1 {
+		return errors.New("--limit must be at least 1")
+	}
+
+	return nil
+}
+
+// selective tells whether the filter may leave out messages, other than by its limit.
+func (f *deadLetterFilter) selective() bool {
+	return f.reason != "" || f.routingKey != "" || f.since != "" || f.until != ""
+}
+
+func (f *deadLetterFilter) matches(letter rabbitmq.DeadLetter) bool {
+	switch {
+	case f.reason != "" && letter.Reason != f.reason:
+		return false
+	case f.routingKey != "" && letter.RoutingKey != f.routingKey:
+		return false
+	case !f.sinceTime.IsZero() && letter.RejectedAt.Before(f.sinceTime):
+		return false
+	case !f.untilTime.IsZero() && !letter.RejectedAt.Before(f.untilTime):
+		return false
+	default:
+		return true
+	}
+}
+
+// parseTimeFlag parses a time given as RFC 3339 or as a duration before now. An empty value is the zero time.
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// deadLetters are messages taken from a dead-letter queue without acknowledging them, which keeps other
+// consumers from receiving them. Those that are not acknowledged are put back in the queue by release.
+type deadLetters struct {
+	letters []rabbitmq.DeadLetter
+	acked   map[uint64]bool
+}
+
+// fetchDeadLetters takes up to limit messages from the dead-letter queue of queue.
+func fetchDeadLetters(mqchannel *amqp.Channel, queue string, limit int) (*deadLetters, error) {
+	fetched := &deadLetters{acked: make(map[uint64]bool)}
+
+	for len(fetched.letters) < limit {
+		delivery, ok, err := mqchannel.Get(rabbitmq.DeadLetterQueue(queue), false)
+
+		if err != nil {
+			return fetched, fmt.Errorf("failed to get a message from the dead-letter queue of %s: %w", queue, err)
+		}
+
+		if !ok {
+			break
+		}
+
+		fetched.letters = append(fetched.letters, rabbitmq.ParseDeadLetter(delivery))
+	}
+
+	return fetched, nil
+}
+
+// matching returns the fetched messages that match filter.
+func (d *deadLetters) matching(filter *deadLetterFilter) []rabbitmq.DeadLetter {
+	matches := make([]rabbitmq.DeadLetter, 0)
+
+	for _, letter := range d.letters {
+		if filter.matches(letter) {
+			matches = append(matches, letter)
+		}
+	}
+
+	return matches
+}
+
+// ack removes a message from the dead-letter queue for good.
+func (d *deadLetters) ack(letter rabbitmq.DeadLetter) error {
+	if err := letter.Delivery.Ack(false); err != nil {
+		return err
+	}
+
+	d.acked[letter.Delivery.DeliveryTag] = true
+
+	return nil
+}
+
+// release puts the messages that were not acknowledged back in the dead-letter queue. It returns the first
+// error, after trying to release every message.
+func (d *deadLetters) release() error {
+	var first error
+
+	for _, letter := range d.letters {
+		if d.acked[letter.Delivery.DeliveryTag] {
+			continue
+		}
+
+		if err := letter.Delivery.Nack(false, true); err != nil && first == nil {
+			first = fmt.Errorf("failed to put a message back in its dead-letter queue: %w", err)
+		}
+	}
+
+	return first
+}
+
+// deadLetterRecord is how list and export show a dead-lettered message.
+type deadLetterRecord struct {
+	MessageId        string          `json:"messageId,omitempty"`
+	Queue            string          `json:"queue,omitempty"`
+	Exchange         string          `json:"exchange,omitempty"`
+	RoutingKey       string          `json:"routingKey,omitempty"`
+	Reason           string          `json:"reason,omitempty"`
+	Error            string          `json:"error,omitempty"`
+	ValidationErrors json.RawMessage `json:"validationErrors,omitempty"`
+	RejectedAt       *time.Time      `json:"rejectedAt,omitempty"`
+	FirstReason      string          `json:"firstReason,omitempty"`
+	FirstRejectedAt  *time.Time      `json:"firstRejectedAt,omitempty"`
+	Deaths           int             `json:"deaths"`
+	Message          json.RawMessage `json:"message,omitempty"`
+	// Body holds the raw body, base64-encoded, of a message that could not be decoded as prototype.
+	Body        []byte `json:"body,omitempty"`
+	DecodeError string `json:"decodeError,omitempty"`
+}
+
+func newDeadLetterRecord(letter rabbitmq.DeadLetter, prototype proto.Message) deadLetterRecord {
+	record := deadLetterRecord{
+		MessageId:       letter.Delivery.MessageId,
+		Queue:           letter.Queue,
+		Exchange:        letter.Exchange,
+		RoutingKey:      letter.RoutingKey,
+		Reason:          letter.Reason,
+		Error:           letter.Error,
+		RejectedAt:      optionalTime(letter.RejectedAt),
+		FirstReason:     letter.FirstReason,
+		FirstRejectedAt: optionalTime(letter.FirstRejectedAt),
+		Deaths:          letter.Deaths,
+	}
+
+	if json.Valid([]byte(letter.ValidationErrors)) {
+		record.ValidationErrors = json.RawMessage(letter.ValidationErrors)
+	}
+
+	message, err := decodeMessage(letter.Delivery.Body, prototype)
+
+	if err != nil {
+		record.Body = letter.Delivery.Body
+		record.DecodeError = err.Error()
+	} else {
+		record.Message = message
+	}
+
+	return record
+}
+
+func optionalTime(value time.Time) *time.Time {
+	if value.IsZero() {
+		return nil
+	}
+
+	return &value
+}
+
+// decodeMessage decodes a protobuf body as a message of the type of prototype, and returns it as protojson.
+func decodeMessage(body []byte, prototype proto.Message) ([]byte, error) {
+	message := proto.Clone(prototype)
+
+	if err := proto.Unmarshal(body, message); err != nil {
+		return nil, err
+	}
+
+	return protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(message)
+}
+
+// encodeMessage parses protojson as a message of the type of prototype, and returns it as a protobuf body.
+func encodeMessage(document []byte, prototype proto.Message) ([]byte, error) {
+	message := proto.Clone(prototype)
+
+	if err := protojson.Unmarshal(document, message); err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(message)
+}
+
+// editInEditor lets the user edit content in $VISUAL or $EDITOR, and returns the edited content.
+func editInEditor(content []byte) ([]byte, error) {
+	editor := os.Getenv("VISUAL")
+
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = "vi"
+	}
+
+	file, err := os.CreateTemp("", "kwekker-dead-letter-*.json")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The editor may be given with arguments, e.g. "code --wait".
+	args := append(strings.Fields(editor), file.Name())
+	command := exec.Command(args[0], args[1:]...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	if err = command.Run(); err != nil {
+		return nil, fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+
+	return os.ReadFile(file.Name())
+}
+
+// confirm asks a yes or no question on the output of cmd, and reads the answer from its input.
+func confirm(cmd *cobra.Command, question string) (bool, error) {
+	fmt.Fprintf(cmd.OutOrStdout(), "%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes", nil
+}
+
+// isBlank tells whether an edited document was emptied, which skips the message.
+func isBlank(document []byte) bool {
+	return len(bytes.TrimSpace(document)) == 0
+}
@@ -0,0 +1,414 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/spf13/cobra"
+	"kwekker-worker/pkg/config"
+	"kwekker-worker/pkg/rabbitmq"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func newDlqCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "dlq",
+		Short: "Show how many messages every dead-letter queue holds, and inspect, replay, export or purge them",
+		Long: `Show how many messages every dead-letter queue holds, and inspect, replay, export or purge them.
+
+The subcommands take messages from the dead-letter queue of a queue, e.g. kwek.create, without
+acknowledging them, and put back every message they do not replay or purge. While they run, no other
+consumer receives the messages they hold. Filters select messages among the first --limit ones.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := loadEnvironment()
+
+			if err != nil {
+				return err
+			}
+
+			conn, mqchannel, err := env.openChannel()
+
+			if err != nil {
+				return err
+			}
+
+			defer conn.Close()
+
+			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "QUEUE\tMESSAGES")
+
+			for _, queue := range queueNames() {
+				declared, err := inspectDeadLetterQueue(mqchannel, queue)
+
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(writer, "%s\t%d\n", declared.Name, declared.Messages)
+			}
+
+			return writer.Flush()
+		},
+	}
+
+	command.AddCommand(
+		newDlqListCommand(),
+		newDlqExportCommand(),
+		newDlqReplayCommand(),
+		newDlqPurgeCommand(),
+	)
+
+	return command
+}
+
+func inspectDeadLetterQueue(mqchannel *amqp.Channel, queue string) (amqp.Queue, error) {
+	// A passive declaration fails when the queue does not exist, which also closes the channel.
+	declared, err := mqchannel.QueueDeclarePassive(rabbitmq.DeadLetterQueue(queue), true, false, false, false, nil)
+
+	if err != nil {
+		return declared, fmt.Errorf("failed to inspect the dead-letter queue of %s; has the worker declared it? %w", queue, err)
+	}
+
+	return declared, nil
+}
+
+// deadLetterAction is what a dlq subcommand does with the messages it fetched from a dead-letter queue.
+type deadLetterAction func(mqchannel *amqp.Channel, queueData config.QueueData, fetched *deadLetters) error
+
+// withDeadLetters fetches messages from the dead-letter queue of queue for action, and puts back the ones
+// action did not acknowledge.
+func withDeadLetters(queue string, filter *deadLetterFilter, action deadLetterAction) error {
+	if err := filter.resolve(time.Now()); err != nil {
+		return err
+	}
+
+	env, err := loadEnvironment()
+
+	if err != nil {
+		return err
+	}
+
+	queueData, err := env.queue(queue)
+
+	if err != nil {
+		return err
+	}
+
+	// Closing the connection also puts back the messages that could not be released.
+	conn, mqchannel, err := env.openChannel()
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	fetched, err := fetchDeadLetters(mqchannel, queue, filter.limit)
+
+	if err == nil {
+		err = action(mqchannel, queueData, fetched)
+	}
+
+	if releaseErr := fetched.release(); err == nil {
+		err = releaseErr
+	}
+
+	return err
+}
+
+func newDlqListCommand() *cobra.Command {
+	filter := &deadLetterFilter{}
+
+	command := &cobra.Command{
+		Use:   "list <queue>",
+		Short: "Show the messages in the dead-letter queue of a queue as JSON, with why they were rejected",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withDeadLetters(args[0], filter, func(_ *amqp.Channel, queueData config.QueueData, fetched *deadLetters) error {
+				matches := fetched.matching(filter)
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+
+				for _, letter := range matches {
+					if err := encoder.Encode(newDeadLetterRecord(letter, queueData.Type)); err != nil {
+						return err
+					}
+				}
+
+				fmt.Fprintf(cmd.ErrOrStderr(), "%d of the %d messages looked at match\n", len(matches), len(fetched.letters))
+
+				return nil
+			})
+		},
+	}
+
+	filter.addFlags(command)
+
+	return command
+}
+
+func newDlqExportCommand() *cobra.Command {
+	var (
+		filter = &deadLetterFilter{}
+		output string
+	)
+
+	command := &cobra.Command{
+		Use:   "export <queue>",
+		Short: "Write the messages in the dead-letter queue of a queue to a file as a JSON array, leaving them in the queue",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" {
+				return errors.New("set --output to the file to export to, or - for stdout")
+			}
+
+			return withDeadLetters(args[0], filter, func(_ *amqp.Channel, queueData config.QueueData, fetched *deadLetters) error {
+				matches := fetched.matching(filter)
+				records := make([]deadLetterRecord, len(matches))
+
+				for i, letter := range matches {
+					records[i] = newDeadLetterRecord(letter, queueData.Type)
+				}
+
+				encoded, err := json.MarshalIndent(records, "", "  ")
+
+				if err != nil {
+					return err
+				}
+
+				encoded = append(encoded, '\n')
+
+				if output == "-" {
+					_, err = cmd.OutOrStdout().Write(encoded)
+					return err
+				}
+
+				if err = os.WriteFile(output, encoded, 0o644); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "Exported %d messages to %s\n", len(records), output)
+
+				return nil
+			})
+		},
+	}
+
+	filter.addFlags(command)
+	command.Flags().StringVarP(&output, "output", "o", "", "file to write the messages to; - writes stdout")
+
+	return command
+}
+
+func newDlqReplayCommand() *cobra.Command {
+	var (
+		filter = &deadLetterFilter{}
+		edit   bool
+	)
+
+	command := &cobra.Command{
+		Use:   "replay <queue>",
+		Short: "Publish the messages in the dead-letter queue of a queue again to the exchange they were published to",
+		Long: `Publish the messages in the dead-letter queue of a queue again to the exchange they were published to.
+
+A message is removed from the dead-letter queue once the broker has confirmed its replay. With --edit,
+every message is opened as JSON in $VISUAL or $EDITOR first; emptying the file skips the message.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withDeadLetters(args[0], filter, func(mqchannel *amqp.Channel, queueData config.QueueData, fetched *deadLetters) error {
+				publisher, err := rabbitmq.NewPublisher(mqchannel)
+
+				if err != nil {
+					return err
+				}
+
+				out := cmd.OutOrStdout()
+				matches := fetched.matching(filter)
+				replayed := 0
+
+				for i, letter := range matches {
+					body := letter.Delivery.Body
+
+					if edit {
+						edited, err := editDeadLetter(letter, queueData)
+
+						if err != nil {
+							fmt.Fprintf(out, "%d\tfailed\t%s\t%s\n", i+1, letter.Delivery.MessageId, err)
+							continue
+						}
+
+						if edited == nil {
+							fmt.Fprintf(out, "%d\tskipped\t%s\n", i+1, letter.Delivery.MessageId)
+							continue
+						}
+
+						body = edited
+					}
+
+					if err = publisher.Replay(context.Background(), letter, body); err != nil {
+						fmt.Fprintf(out, "%d\tfailed\t%s\t%s\n", i+1, letter.Delivery.MessageId, err)
+						continue
+					}
+
+					if err = fetched.ack(letter); err != nil {
+						return fmt.Errorf("replayed message %s, but failed to remove it from the dead-letter queue: %w", letter.Delivery.MessageId, err)
+					}
+
+					replayed++
+					fmt.Fprintf(out, "%d\treplayed\t%s\n", i+1, letter.Delivery.MessageId)
+				}
+
+				fmt.Fprintf(out, "Replayed %d of %d messages\n", replayed, len(matches))
+
+				return nil
+			})
+		},
+	}
+
+	filter.addFlags(command)
+	command.Flags().BoolVar(&edit, "edit", false, "edit every message as JSON before replaying it")
+
+	return command
+}
+
+// editDeadLetter lets the user edit a message as JSON, and returns the edited body, or nil to skip it.
+func editDeadLetter(letter rabbitmq.DeadLetter, queueData config.QueueData) ([]byte, error) {
+	document, err := decodeMessage(letter.Delivery.Body, queueData.Type)
+
+	if err != nil {
+		return nil, fmt.Errorf("the message cannot be decoded for editing: %w", err)
+	}
+
+	edited, err := editInEditor(document)
+
+	if err != nil || isBlank(edited) {
+		return nil, err
+	}
+
+	body, err := encodeMessage(edited, queueData.Type)
+
+	if err != nil {
+		return nil, fmt.Errorf("the edited message is invalid: %w", err)
+	}
+
+	return body, nil
+}
+
+func newDlqPurgeCommand() *cobra.Command {
+	var (
+		filter = &deadLetterFilter{}
+		yes    bool
+	)
+
+	command := &cobra.Command{
+		Use:   "purge <queue>",
+		Short: "Delete the messages in the dead-letter queue of a queue, after asking for confirmation",
+		Long: `Delete the messages in the dead-letter queue of a queue, after asking for confirmation.
+
+Without filters the whole dead-letter queue is purged. With filters, only the matching messages among
+the first --limit ones are deleted.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			queue := args[0]
+
+			if !filter.selective() {
+				return purgeDeadLetterQueue(cmd, queue, yes)
+			}
+
+			return withDeadLetters(queue, filter, func(_ *amqp.Channel, _ config.QueueData, fetched *deadLetters) error {
+				matches := fetched.matching(filter)
+
+				if len(matches) == 0 {
+					fmt.Fprintf(cmd.OutOrStdout(), "None of the %d messages looked at match\n", len(fetched.letters))
+					return nil
+				}
+
+				question := fmt.Sprintf("Delete %d of the %d messages looked at in %s?", len(matches), len(fetched.letters), rabbitmq.DeadLetterQueue(queue))
+
+				if ok, err := confirmed(cmd, question, yes); !ok {
+					return err
+				}
+
+				for _, letter := range matches {
+					if err := fetched.ack(letter); err != nil {
+						return err
+					}
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "Deleted %d messages\n", len(matches))
+
+				return nil
+			})
+		},
+	}
+
+	filter.addFlags(command)
+	command.Flags().BoolVarP(&yes, "yes", "y", false, "do not ask for confirmation")
+
+	return command
+}
+
+func purgeDeadLetterQueue(cmd *cobra.Command, queue string, yes bool) error {
+	env, err := loadEnvironment()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = env.queue(queue); err != nil {
+		return err
+	}
+
+	conn, mqchannel, err := env.openChannel()
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	declared, err := inspectDeadLetterQueue(mqchannel, queue)
+
+	if err != nil {
+		return err
+	}
+
+	if declared.Messages == 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s is empty\n", declared.Name)
+		return nil
+	}
+
+	if ok, err := confirmed(cmd, fmt.Sprintf("Delete all %d messages in %s?", declared.Messages, declared.Name), yes); !ok {
+		return err
+	}
+
+	purged, err := mqchannel.QueuePurge(declared.Name, false)
+
+	if err != nil {
+		return fmt.Errorf("failed to purge %s: %w", declared.Name, err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Deleted %d messages\n", purged)
+
+	return nil
+}
+
+// confirmed tells whether to go ahead, asking question unless yes is set.
+func confirmed(cmd *cobra.Command, question string, yes bool) (bool, error) {
+	if yes {
+		return true, nil
+	}
+
+	ok, err := confirm(cmd, question)
+
+	if err == nil && !ok {
+		fmt.Fprintln(cmd.OutOrStdout(), "Nothing was deleted")
+	}
+
+	return ok, err
+}
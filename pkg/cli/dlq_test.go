@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"bytes"
+	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+	"kwekker-worker/pkg/rabbitmq"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterFilter(t *testing.T) {
+	now := time.Date(2022, 11, 2, 12, 0, 0, 0, time.UTC)
+	filter := &deadLetterFilter{reason: "conflict", routingKey: "create", since: "2h", until: now.Add(-time.Hour).Format(time.RFC3339), limit: 10}
+
+	if err := filter.resolve(now); err != nil {
+		t.Fatalf("Filter should be resolved, but is not: %v", err)
+	}
+
+	matching := rabbitmq.DeadLetter{Reason: "conflict", RoutingKey: "create", RejectedAt: now.Add(-90 * time.Minute)}
+
+	if !filter.matches(matching) {
+		t.Errorf("Filter should match %+v, but does not", matching)
+	}
+
+	others := []rabbitmq.DeadLetter{
+		{Reason: "rate limited", RoutingKey: "create", RejectedAt: matching.RejectedAt},
+		{Reason: "conflict", RoutingKey: "update", RejectedAt: matching.RejectedAt},
+		{Reason: "conflict", RoutingKey: "create", RejectedAt: now.Add(-3 * time.Hour)},
+		{Reason: "conflict", RoutingKey: "create", RejectedAt: now.Add(-time.Hour)},
+	}
+
+	for _, other := range others {
+		if filter.matches(other) {
+			t.Errorf("Filter should not match %+v, but does", other)
+		}
+	}
+
+	if err := (&deadLetterFilter{since: "yesterday", limit: 10}).resolve(now); err == nil {
+		t.Errorf("Filter with an unparseable time should not be resolved, but is")
+	}
+}
+
+func TestDeadLetterRecordDecodesMessage(t *testing.T) {
+	body, err := proto.Marshal(&kwekproto.DeleteKwek{KwekGuid: "not a guid"})
+
+	if err != nil {
+		t.Fatalf("Message should be marshalled, but is not: %v", err)
+	}
+
+	letter := rabbitmq.DeadLetter{
+		Delivery:         amqp.Delivery{MessageId: "1", Body: body},
+		Reason:           "validation failed",
+		ValidationErrors: `[{"field":"kwekGuid","message":"must be a GUID"}]`,
+		Deaths:           1,
+	}
+
+	record := newDeadLetterRecord(letter, &kwekproto.DeleteKwek{})
+
+	if !strings.Contains(string(record.Message), "not a guid") || record.DecodeError != "" {
+		t.Errorf("Message should be decoded as JSON, but is %s (%s)", record.Message, record.DecodeError)
+	}
+
+	if len(record.ValidationErrors) == 0 || record.RejectedAt != nil {
+		t.Errorf("Record should hold the validation errors and no rejection time, but is %+v", record)
+	}
+
+	letter.Delivery.Body = []byte{0xff, 0xff}
+	record = newDeadLetterRecord(letter, &kwekproto.DeleteKwek{})
+
+	if record.Message != nil || record.DecodeError == "" || !bytes.Equal(record.Body, letter.Delivery.Body) {
+		t.Errorf("Undecodable message should be kept as its body with the decode error, but is %+v", record)
+	}
+
+	encoded, err := encodeMessage([]byte(`{"kwekGuid": "edited"}`), &kwekproto.DeleteKwek{})
+	decoded := &kwekproto.DeleteKwek{}
+
+	if err != nil || proto.Unmarshal(encoded, decoded) != nil || decoded.GetKwekGuid() != "edited" {
+		t.Errorf("Edited JSON should be encoded as the message, but is %v (%v)", decoded, err)
+	}
+}
+
+func TestConfirmReadsAnswer(t *testing.T) {
+	answers := map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false}
+
+	for answer, expected := range answers {
+		cmd := &cobra.Command{}
+		cmd.SetIn(strings.NewReader(answer))
+		cmd.SetOut(&bytes.Buffer{})
+
+		if ok, err := confirm(cmd, "Delete?"); err != nil || ok != expected {
+			t.Errorf("Answer %q should confirm %t, but confirms %t (%v)", answer, expected, ok, err)
+		}
+	}
+}
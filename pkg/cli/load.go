@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"kwekker-worker/pkg/db"
+	"kwekker-worker/pkg/rabbitmq"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+func newLoadCommand() *cobra.Command {
+	var (
+		rate         float64
+		duration     time.Duration
+		count        int64
+		concurrency  int
+		mix          string
+		invalidShare float64
+		seed         int64
+	)
+
+	command := &cobra.Command{
+		Use:   "load",
+		Short: "Publish generated traffic and summarize how it went",
+		Long: `Publish generated traffic and summarize how it went.
+
+Messages are picked by the weights of --mix. Kweks, updates and deletes are about users and kweks created
+earlier in the same run, once the database shows that the worker has stored them, so that they target
+entities that exist; until there are any, creates are published instead. Every message is published with
+publisher confirms.
+
+The worker under load should run with RATELIMIT_ENABLED=false, as it would otherwise dead-letter most of the
+generated kweks as rate limited.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			weights, err := parseMix(mix)
+
+			if err != nil {
+				return err
+			}
+
+			if duration <= 0 && count <= 0 {
+				return errors.New("set --duration, --count or both, so that the run ends")
+			}
+
+			// Faster rates round the interval between messages down to zero, which a ticker cannot have.
+			if rate < 0 || rate > float64(time.Second) {
+				return fmt.Errorf("--rate must be between 0 and %d", time.Second)
+			}
+
+			if concurrency < 1 {
+				return errors.New("--concurrency must be at least 1")
+			}
+
+			if invalidShare < 0 || invalidShare > 1 {
+				return errors.New("--invalid must be between 0 and 1")
+			}
+
+			env, err := loadEnvironment()
+
+			if err != nil {
+				return err
+			}
+
+			if env.config.RateLimit.Enabled {
+				fmt.Fprintln(cmd.ErrOrStderr(), "Warning: RATELIMIT_ENABLED is true; a worker with this configuration dead-letters most generated kweks as rate limited")
+			}
+
+			ctx := context.Background()
+
+			if duration > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, duration)
+				defer cancel()
+			}
+
+			// A single ticker shared by the publishers limits their combined rate.
+			var ticks <-chan time.Time
+
+			if rate > 0 {
+				ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+				defer ticker.Stop()
+				ticks = ticker.C
+			}
+
+			generator := newGenerator(weights, invalidShare, seed)
+			summary := newLoadSummary()
+
+			conn := db.NewDB(env.logger, env.config.Postgres).Connect()
+			defer conn.Close(context.Background())
+
+			confirming, stopConfirming := context.WithCancel(context.Background())
+			defer stopConfirming()
+
+			confirmed := make(chan error, 1)
+
+			go func() { confirmed <- confirmStored(confirming, conn, generator) }()
+
+			started := time.Now()
+
+			var (
+				sent   int64
+				wait   sync.WaitGroup
+				failed error
+				once   sync.Once
+			)
+
+			for i := 0; i < concurrency; i++ {
+				conn, mqchannel, err := env.openChannel()
+
+				if err != nil {
+					return err
+				}
+
+				defer conn.Close()
+
+				publisher, err := rabbitmq.NewPublisher(mqchannel)
+
+				if err != nil {
+					return err
+				}
+
+				wait.Add(1)
+
+				go func() {
+					defer wait.Done()
+
+					for {
+						if ticks != nil {
+							select {
+							case <-ctx.Done():
+								return
+							case <-ticks:
+							}
+						} else if ctx.Err() != nil {
+							return
+						}
+
+						if count > 0 && atomic.AddInt64(&sent, 1) > count {
+							return
+						}
+
+						message := generator.next()
+						queueData, err := env.queue(message.queue)
+
+						if err != nil {
+							once.Do(func() { failed = err })
+							return
+						}
+
+						// The run may end while a message is published; it is still waited for, so that it is counted.
+						_, err = publisher.Publish(context.Background(), queueData, message.message)
+
+						if err != nil {
+							message.failed()
+						}
+
+						summary.record(message, err)
+					}
+				}()
+			}
+
+			wait.Wait()
+			stopConfirming()
+
+			if err = <-confirmed; err != nil {
+				return fmt.Errorf("failed to check which generated users and kweks are stored: %w", err)
+			}
+
+			if failed != nil {
+				return failed
+			}
+
+			return printLoadSummary(cmd, summary, time.Since(started))
+		},
+	}
+
+	command.Flags().Float64Var(&rate, "rate", 100, "messages to publish per second across all publishers; 0 publishes as fast as possible")
+	command.Flags().DurationVar(&duration, "duration", 10*time.Second, "how long to publish for; 0 publishes until --count messages are published")
+	command.Flags().Int64Var(&count, "count", 0, "number of messages to publish at most; 0 does not limit the number")
+	command.Flags().IntVar(&concurrency, "concurrency", 1, "number of publishers, each with its own channel")
+	command.Flags().StringVar(&mix, "mix", defaultMix, "comma-separated queue=weight pairs giving the share of each message type")
+	command.Flags().Float64Var(&invalidShare, "invalid", 0, "share of messages, between 0 and 1, that is deliberately invalid")
+	command.Flags().Int64Var(&seed, "seed", time.Now().UnixNano(), "seed of the random choices, to repeat a run")
+
+	return command
+}
+
+// confirmStored polls the database for the users and kweks generator created, and makes the ones that are
+// stored available to the messages about them, until ctx is done.
+func confirmStored(ctx context.Context, conn db.Querier, generator *generator) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		userIds, kwekGuids := generator.pending()
+
+		if len(userIds) == 0 && len(kwekGuids) == 0 {
+			continue
+		}
+
+		stored, err := db.ExistingEntities(ctx, conn, userIds, kwekGuids)
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		generator.confirm(stored)
+	}
+}
+
+func printLoadSummary(cmd *cobra.Command, summary *loadSummary, elapsed time.Duration) error {
+	out := cmd.OutOrStdout()
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "QUEUE\tPUBLISHED\tINVALID\tFAILED\t")
+
+	for _, queue := range queueNames() {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t\n", queue, summary.published[queue], summary.invalid[queue], summary.failed[queue])
+	}
+
+	published, invalid, failed := summary.totals()
+	fmt.Fprintf(writer, "total\t%d\t%d\t%d\t\n", published, invalid, failed)
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	throughput := float64(published+invalid) / elapsed.Seconds()
+	fmt.Fprintf(out, "\nPublished %d messages in %s (%.1f/s); %d failed\n", published+invalid, elapsed.Round(time.Millisecond), throughput, failed)
+
+	for _, message := range summary.errorMessages() {
+		fmt.Fprintf(out, "  %s\n", message)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d messages could not be published", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	userproto "github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"io"
+	"kwekker-worker/pkg/validation"
+	"strings"
+	"testing"
+)
+
+func TestParseMix(t *testing.T) {
+	if _, err := parseMix(defaultMix); err != nil {
+		t.Errorf("Default mix should be valid, but is not: %v", err)
+	}
+
+	for _, mix := range []string{"kwek.create", "kwek.create=-1", "kwek.create=0", "kwek.publish=1"} {
+		if _, err := parseMix(mix); err == nil {
+			t.Errorf("Mix %q should be rejected, but is not", mix)
+		}
+	}
+}
+
+func TestGeneratorTargetsCreatedEntities(t *testing.T) {
+	mix, _ := parseMix(defaultMix)
+	generator := newGenerator(mix, 0, 1)
+
+	users := make(map[string]bool)
+	kweks := make(map[string]bool)
+
+	for i := 0; i < 2000; i++ {
+		message := generator.next()
+
+		if result := validation.Validate(message.message); !result.Valid {
+			t.Fatalf("Generated %s message should be valid, but is not: %v", message.queue, result.Errors)
+		}
+
+		switch m := message.message.(type) {
+		case *userproto.CreateUser:
+			users[m.GetUserId()] = true
+		case *userproto.UpdateUser:
+			if !users[m.GetUserId()] {
+				t.Fatalf("Updated user %s should have been created, but was not", m.GetUserId())
+			}
+		case *userproto.DeleteUser:
+			if !users[m.GetUserId()] {
+				t.Fatalf("Deleted user %s should have been created, but was not", m.GetUserId())
+			}
+
+			delete(users, m.GetUserId())
+		case *kwekproto.CreateKwek:
+			if !users[m.GetUserId()] {
+				t.Fatalf("Kwek should be posted by a created user, but is posted by %s", m.GetUserId())
+			}
+
+			kweks[m.GetKwekGuid()] = true
+		case *kwekproto.UpdateKwek:
+			if !kweks[m.GetKwekGuid()] {
+				t.Fatalf("Updated kwek %s should have been created, but was not", m.GetKwekGuid())
+			}
+		case *kwekproto.DeleteKwek:
+			if !kweks[m.GetKwekGuid()] {
+				t.Fatalf("Deleted kwek %s should have been created, but was not", m.GetKwekGuid())
+			}
+
+			delete(kweks, m.GetKwekGuid())
+		}
+
+		// Everything the generator created is stored right away.
+		storeAll(generator)
+	}
+
+	if len(kweks) == 0 {
+		t.Errorf("Generator should have created kweks, but has not")
+	}
+}
+
+// storeAll confirms every user and kwek generator is waiting for, as if the worker had stored them.
+func storeAll(generator *generator) {
+	userIds, kwekGuids := generator.pending()
+	stored := make(map[string]bool)
+
+	for _, id := range append(userIds, kwekGuids...) {
+		stored[id] = true
+	}
+
+	generator.confirm(stored)
+}
+
+func TestGeneratorWaitsUntilEntitiesAreStored(t *testing.T) {
+	mix, _ := parseMix("kwek.create=1,user.delete=1")
+	generator := newGenerator(mix, 0, 1)
+
+	for i := 0; i < 10; i++ {
+		if message := generator.next(); message.queue != "user.create" {
+			t.Fatalf("Only users should be created until one is stored, but %s was generated", message.queue)
+		}
+	}
+
+	storeAll(generator)
+
+	var kwek *kwekproto.CreateKwek
+
+	for kwek == nil {
+		message := generator.next()
+
+		switch m := message.message.(type) {
+		case *kwekproto.CreateKwek:
+			kwek = m
+		case *userproto.DeleteUser:
+		default:
+			t.Fatalf("Only kweks should be created and users deleted, but %s was generated", message.queue)
+		}
+	}
+
+	// Until the kwek is stored, deleting its user could remove the user before the worker stores the kwek.
+	for i := 0; i < 100; i++ {
+		if m, ok := generator.next().message.(*userproto.DeleteUser); ok && m.GetUserId() == kwek.GetUserId() {
+			t.Fatalf("User %s should not be deleted while their kwek is pending, but is", kwek.GetUserId())
+		}
+	}
+}
+
+func TestGeneratorForgetsCreatesThatFailed(t *testing.T) {
+	mix, _ := parseMix("user.create=1")
+	generator := newGenerator(mix, 0, 1)
+
+	generator.next().failed()
+
+	if userIds, _ := generator.pending(); len(userIds) != 0 {
+		t.Errorf("User that could not be published should not be pending, but %v are", userIds)
+	}
+}
+
+func TestGeneratorInvalidShare(t *testing.T) {
+	mix, _ := parseMix(defaultMix)
+	generator := newGenerator(mix, 1, 1)
+
+	for i := 0; i < 100; i++ {
+		message := generator.next()
+
+		if !message.invalid {
+			t.Fatalf("Every message should be invalid, but %s is not", message.queue)
+		}
+
+		if result := validation.Validate(message.message); result.Valid {
+			t.Errorf("Invalid %s message should fail validation, but does not", message.queue)
+		}
+	}
+}
+
+func TestLoadRejectsRatesATickerCannotHave(t *testing.T) {
+	for _, rate := range []string{"-1", "2e9"} {
+		command := newLoadCommand()
+		command.SetArgs([]string{"--rate", rate})
+		command.SetOut(io.Discard)
+		command.SetErr(io.Discard)
+
+		if err := command.Execute(); err == nil || !strings.Contains(err.Error(), "--rate") {
+			t.Errorf("Rate %s should be rejected, but the error is %v", rate, err)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	userproto "github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultMix is the share of each message type in generated traffic, roughly that of real traffic.
+const defaultMix = "kwek.create=50,kwek.update=20,kwek.delete=10,user.create=10,user.update=8,user.delete=2"
+
+type weightedQueue struct {
+	queue  string
+	weight int
+}
+
+// parseMix parses a comma-separated list of queue=weight pairs, such as defaultMix.
+func parseMix(mix string) ([]weightedQueue, error) {
+	weights := make([]weightedQueue, 0)
+	total := 0
+
+	for _, entry := range strings.Split(mix, ",") {
+		queue, weight, found := strings.Cut(strings.TrimSpace(entry), "=")
+
+		if !found {
+			return nil, fmt.Errorf("mix entry %q is not of the form queue=weight", entry)
+		}
+
+		parsed, err := strconv.Atoi(weight)
+
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("mix entry %q does not have a weight of zero or more", entry)
+		}
+
+		if !isKnownQueue(queue) {
+			return nil, fmt.Errorf("mix entry %q names an unknown queue; known queues are %s", entry, strings.Join(queueNames(), ", "))
+		}
+
+		weights = append(weights, weightedQueue{queue: queue, weight: parsed})
+		total += parsed
+	}
+
+	if total == 0 {
+		return nil, fmt.Errorf("mix %q gives no queue a weight", mix)
+	}
+
+	return weights, nil
+}
+
+func isKnownQueue(queue string) bool {
+	for _, known := range queueNames() {
+		if queue == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+type trackedKwek struct {
+	guid   string
+	userId string
+}
+
+// generator generates a mix of messages about the users and kweks it created itself, so that updates
+// and deletes target entities that exist. It is safe for concurrent use.
+//
+// The worker consumes the queues concurrently, so a kwek can be handled before the user who posts it is
+// stored, and a user can be deleted before their kwek is stored. Created users and kweks are therefore pending
+// until confirm reports that they are stored, and only then do other messages refer to them.
+type generator struct {
+	mu           sync.Mutex
+	random       *rand.Rand
+	mix          []weightedQueue
+	invalidShare float64
+	users        []string
+	kweks        []trackedKwek
+	pendingUsers []string
+	pendingKweks []trackedKwek
+}
+
+// generated is a message made by generator.
+type generated struct {
+	queue   string
+	message proto.Message
+	// invalid messages are meant to be dead-lettered by the worker.
+	invalid bool
+	// failed forgets the entity a create message is about, when the message could not be published.
+	failed func()
+}
+
+func newGenerator(mix []weightedQueue, invalidShare float64, seed int64) *generator {
+	return &generator{
+		random:       rand.New(rand.NewSource(seed)),
+		mix:          mix,
+		invalidShare: invalidShare,
+	}
+}
+
+// next generates the next message. A message that needs an entity that is not stored yet is replaced by
+// the message creating it: an update of a kwek when there are no kweks becomes a kwek.create, which becomes
+// a user.create when there are no users either. A user with pending kweks is updated instead of deleted.
+func (g *generator) next() generated {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	queue := g.pickQueue()
+
+	if (queue == "kwek.update" || queue == "kwek.delete") && len(g.kweks) == 0 {
+		queue = "kwek.create"
+	}
+
+	if (queue == "kwek.create" || queue == "user.update" || queue == "user.delete") && len(g.users) == 0 {
+		queue = "user.create"
+	}
+
+	userIndex := 0
+
+	if len(g.users) > 0 {
+		userIndex = g.random.Intn(len(g.users))
+	}
+
+	if queue == "user.delete" && g.hasPendingKweks(g.users[userIndex]) {
+		queue = "user.update"
+	}
+
+	if g.random.Float64() < g.invalidShare {
+		return generated{queue: queue, message: invalidMessage(queue), invalid: true, failed: func() {}}
+	}
+
+	now := timestamppb.Now()
+
+	switch queue {
+	case "kwek.create":
+		// The kwek is pending from now on rather than once it is published, so that its user is not deleted
+		// in the meantime.
+		kwek := trackedKwek{guid: uuid.NewString(), userId: g.users[userIndex]}
+		g.pendingKweks = append(g.pendingKweks, kwek)
+
+		return generated{
+			queue:   queue,
+			message: &kwekproto.CreateKwek{KwekGuid: kwek.guid, Text: g.text(), UserId: kwek.userId, PostedAt: now},
+			failed:  g.tracking(func() { g.pendingKweks = withoutKwek(g.pendingKweks, kwek.guid) }),
+		}
+	case "kwek.update":
+		kwek := g.kweks[g.random.Intn(len(g.kweks))]
+
+		return generated{queue: queue, message: &kwekproto.UpdateKwek{KwekGuid: kwek.guid, Text: g.text(), UpdatedAt: now}, failed: func() {}}
+	case "kwek.delete":
+		// The kwek is forgotten right away, so that no other message is generated about it.
+		kwek := g.forgetKwek(g.random.Intn(len(g.kweks)))
+
+		return generated{queue: queue, message: &kwekproto.DeleteKwek{KwekGuid: kwek.guid}, failed: func() {}}
+	case "user.create":
+		userId := uuid.NewString()
+		username := "load" + strings.ReplaceAll(userId, "-", "")[:10]
+		g.pendingUsers = append(g.pendingUsers, userId)
+
+		return generated{
+			queue: queue,
+			message: &userproto.CreateUser{
+				UserId:      userId,
+				Username:    username,
+				Email:       username + "@example.com",
+				DisplayName: "Load " + username[4:],
+				AvatarUrl:   "https://example.com/avatars/" + username + ".png",
+				CreatedAt:   now,
+			},
+			failed: g.tracking(func() { g.pendingUsers = withoutUser(g.pendingUsers, userId) }),
+		}
+	case "user.update":
+		userId := g.users[userIndex]
+		displayName := fmt.Sprintf("Load user %d", g.random.Intn(1000))
+
+		return generated{queue: queue, message: &userproto.UpdateUser{UserId: userId, DisplayName: &displayName, UpdatedAt: now}, failed: func() {}}
+	default:
+		userId := g.forgetUser(userIndex)
+
+		return generated{queue: "user.delete", message: &userproto.DeleteUser{UserId: userId}, failed: func() {}}
+	}
+}
+
+// pending returns the IDs of the users and the GUIDs of the kweks that were created but are not known to be
+// stored yet.
+func (g *generator) pending() (userIds []string, kwekGuids []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	userIds = append(make([]string, 0, len(g.pendingUsers)), g.pendingUsers...)
+	kwekGuids = make([]string, 0, len(g.pendingKweks))
+
+	for _, kwek := range g.pendingKweks {
+		kwekGuids = append(kwekGuids, kwek.guid)
+	}
+
+	return userIds, kwekGuids
+}
+
+// confirm makes the pending users and kweks whose ID is in stored available to the messages about them.
+func (g *generator) confirm(stored map[string]bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pendingUsers := g.pendingUsers[:0]
+
+	for _, userId := range g.pendingUsers {
+		if stored[userId] {
+			g.users = append(g.users, userId)
+		} else {
+			pendingUsers = append(pendingUsers, userId)
+		}
+	}
+
+	g.pendingUsers = pendingUsers
+	pendingKweks := g.pendingKweks[:0]
+
+	for _, kwek := range g.pendingKweks {
+		if stored[kwek.guid] {
+			g.kweks = append(g.kweks, kwek)
+		} else {
+			pendingKweks = append(pendingKweks, kwek)
+		}
+	}
+
+	g.pendingKweks = pendingKweks
+}
+
+func (g *generator) hasPendingKweks(userId string) bool {
+	for _, kwek := range g.pendingKweks {
+		if kwek.userId == userId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func withoutUser(userIds []string, userId string) []string {
+	for i, id := range userIds {
+		if id == userId {
+			return append(userIds[:i], userIds[i+1:]...)
+		}
+	}
+
+	return userIds
+}
+
+func withoutKwek(kweks []trackedKwek, guid string) []trackedKwek {
+	for i, kwek := range kweks {
+		if kwek.guid == guid {
+			return append(kweks[:i], kweks[i+1:]...)
+		}
+	}
+
+	return kweks
+}
+
+func (g *generator) pickQueue() string {
+	total := 0
+
+	for _, weighted := range g.mix {
+		total += weighted.weight
+	}
+
+	pick := g.random.Intn(total)
+
+	for _, weighted := range g.mix {
+		if pick < weighted.weight {
+			return weighted.queue
+		}
+
+		pick -= weighted.weight
+	}
+
+	return g.mix[len(g.mix)-1].queue
+}
+
+// tracking wraps a change of the tracked entities so that it can run after next has returned.
+func (g *generator) tracking(track func()) func() {
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		track()
+	}
+}
+
+func (g *generator) forgetKwek(index int) trackedKwek {
+	kwek := g.kweks[index]
+	g.kweks[index] = g.kweks[len(g.kweks)-1]
+	g.kweks = g.kweks[:len(g.kweks)-1]
+
+	return kwek
+}
+
+// forgetUser forgets a user together with their kweks, which are deleted with them.
+func (g *generator) forgetUser(index int) string {
+	userId := g.users[index]
+	g.users[index] = g.users[len(g.users)-1]
+	g.users = g.users[:len(g.users)-1]
+
+	kweks := g.kweks[:0]
+
+	for _, kwek := range g.kweks {
+		if kwek.userId != userId {
+			kweks = append(kweks, kwek)
+		}
+	}
+
+	g.kweks = kweks
+
+	return userId
+}
+
+var words = []string{"kwek", "hello", "world", "load", "test", "queue", "worker", "#kwekker", "#load", "postgres", "rabbit"}
+
+func (g *generator) text() string {
+	text := make([]string, 3+g.random.Intn(10))
+
+	for i := range text {
+		text[i] = words[g.random.Intn(len(words))]
+	}
+
+	return strings.Join(text, " ")
+}
+
+// invalidMessage returns a message for queue that fails validation.
+func invalidMessage(queue string) proto.Message {
+	now := timestamppb.Now()
+
+	switch queue {
+	case "kwek.create":
+		return &kwekproto.CreateKwek{KwekGuid: "not a guid", Text: "invalid", UserId: uuid.NewString(), PostedAt: now}
+	case "kwek.update":
+		return &kwekproto.UpdateKwek{KwekGuid: uuid.NewString(), Text: "", UpdatedAt: now}
+	case "kwek.delete":
+		return &kwekproto.DeleteKwek{KwekGuid: "not a guid"}
+	case "user.create":
+		return &userproto.CreateUser{UserId: uuid.NewString(), Username: "x", Email: "not an email", CreatedAt: now}
+	case "user.update":
+		email := "not an email"
+
+		return &userproto.UpdateUser{UserId: uuid.NewString(), Email: &email, UpdatedAt: now}
+	default:
+		return &userproto.DeleteUser{}
+	}
+}
+
+// loadSummary counts the outcomes of a load run. It is safe for concurrent use.
+type loadSummary struct {
+	mu        sync.Mutex
+	published map[string]int
+	invalid   map[string]int
+	failed    map[string]int
+	errors    map[string]int
+}
+
+func newLoadSummary() *loadSummary {
+	return &loadSummary{
+		published: make(map[string]int),
+		invalid:   make(map[string]int),
+		failed:    make(map[string]int),
+		errors:    make(map[string]int),
+	}
+}
+
+func (s *loadSummary) record(message generated, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case err != nil:
+		s.failed[message.queue]++
+		s.errors[err.Error()]++
+	case message.invalid:
+		s.invalid[message.queue]++
+	default:
+		s.published[message.queue]++
+	}
+}
+
+// totals returns the number of valid and invalid messages published and the number of failed publishes.
+func (s *loadSummary) totals() (published int, invalid int, failed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, queue := range queueNames() {
+		published += s.published[queue]
+		invalid += s.invalid[queue]
+		failed += s.failed[queue]
+	}
+
+	return published, invalid, failed
+}
+
+// errorMessages returns the distinct publish errors with how often each occurred, most frequent first.
+func (s *loadSummary) errorMessages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := make([]string, 0, len(s.errors))
+
+	for message := range s.errors {
+		messages = append(messages, message)
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		if s.errors[messages[i]] != s.errors[messages[j]] {
+			return s.errors[messages[i]] > s.errors[messages[j]]
+		}
+
+		return messages[i] < messages[j]
+	})
+
+	for i, message := range messages {
+		messages[i] = fmt.Sprintf("%dx %s", s.errors[message], message)
+	}
+
+	return messages
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"kwekker-worker/pkg/db"
+)
+
+func newMigrateCommand() *cobra.Command {
+	var baseline string
+
+	command := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply the database migrations that have not been applied yet",
+		Long: `Apply the database migrations that have not been applied yet.
+
+Afterwards the canonical usernames and email addresses of existing users are recomputed the way the worker
+computes them, which SQL can only approximate.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := loadEnvironment()
+
+			if err != nil {
+				return err
+			}
+
+			conn := db.NewDB(env.logger, env.config.Postgres).Connect()
+			defer conn.Close(context.Background())
+
+			applied, err := db.Migrate(context.Background(), conn, baseline)
+
+			for _, version := range applied {
+				fmt.Fprintf(cmd.OutOrStdout(), "Applied %s\n", version)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if len(applied) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "The database is up to date")
+			}
+
+			recomputed, err := db.RecomputeCanonicalIdentities(context.Background(), conn)
+
+			if err != nil {
+				return fmt.Errorf("failed to recompute canonical usernames and email addresses: %w", err)
+			}
+
+			if recomputed > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "Recomputed the canonical username and email address of %d users\n", recomputed)
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVar(
+		&baseline,
+		"baseline",
+		"",
+		"record the migrations up to and including this version as applied without running them, for databases created before migrations were tracked",
+	)
+
+	return command
+}
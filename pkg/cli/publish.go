@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"kwekker-worker/pkg/rabbitmq"
+	"kwekker-worker/pkg/validation"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	formatJSON = "json"
+	formatText = "text"
+)
+
+func newPublishCommand() *cobra.Command {
+	var (
+		file     string
+		format   string
+		validate bool
+	)
+
+	command := &cobra.Command{
+		Use:   "publish <queue>",
+		Short: "Publish messages read from a file or stdin to the exchange of a queue, e.g. kwek.create",
+		Long: `Publish messages read from a file or stdin to the exchange of a queue, e.g. kwek.create.
+
+Messages are read as protojson, one JSON object after another or as a JSON array, or as textproto
+with a line holding only --- between messages. Every message is published with publisher confirms,
+and reported as published, invalid or failed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := loadEnvironment()
+
+			if err != nil {
+				return err
+			}
+
+			queueData, err := env.queue(args[0])
+
+			if err != nil {
+				return err
+			}
+
+			input, err := readInput(cmd, file)
+
+			if err != nil {
+				return err
+			}
+
+			if format == "" {
+				format = formatFromExtension(file)
+			}
+
+			messages, err := parseMessages(input, format, queueData.Type)
+
+			if err != nil {
+				return err
+			}
+
+			conn, mqchannel, err := env.openChannel()
+
+			if err != nil {
+				return err
+			}
+
+			defer conn.Close()
+
+			publisher, err := rabbitmq.NewPublisher(mqchannel)
+
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			published := 0
+
+			for i, message := range messages {
+				if validate {
+					if problems := validationProblems(message, validation.CurrentPolicy()); problems != "" {
+						fmt.Fprintf(out, "%d\tinvalid\t%s\n", i+1, problems)
+						continue
+					}
+				}
+
+				messageId, err := publisher.Publish(context.Background(), queueData, message)
+
+				if err != nil {
+					fmt.Fprintf(out, "%d\tfailed\t%s\n", i+1, err)
+					continue
+				}
+
+				published++
+				fmt.Fprintf(out, "%d\tpublished\t%s\n", i+1, messageId)
+			}
+
+			if published < len(messages) {
+				return fmt.Errorf("published %d of %d messages", published, len(messages))
+			}
+
+			fmt.Fprintf(out, "Published %d messages\n", published)
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVarP(&file, "file", "f", "-", "file to read the messages from; - reads stdin")
+	command.Flags().StringVar(&format, "format", "", `"json" or "text"; defaults to text for .txtpb, .textproto and .pbtxt files and json otherwise`)
+	command.Flags().BoolVar(&validate, "validate", false, "validate messages the way the worker does, and skip invalid ones")
+
+	return command
+}
+
+func readInput(cmd *cobra.Command, file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(cmd.InOrStdin())
+	}
+
+	return os.ReadFile(file)
+}
+
+func formatFromExtension(file string) string {
+	switch filepath.Ext(file) {
+	case ".txtpb", ".textproto", ".pbtxt":
+		return formatText
+	default:
+		return formatJSON
+	}
+}
+
+// textSeparator separates the messages of textproto input.
+var textSeparator = regexp.MustCompile(`(?m)^---[ \t]*\r?$`)
+
+// parseMessages parses every message in input as a message of the type of prototype.
+func parseMessages(input []byte, format string, prototype proto.Message) ([]proto.Message, error) {
+	var documents [][]byte
+
+	switch format {
+	case formatJSON:
+		var err error
+
+		if documents, err = splitJSON(input); err != nil {
+			return nil, err
+		}
+	case formatText:
+		for _, document := range textSeparator.Split(string(input), -1) {
+			if strings.TrimSpace(document) != "" {
+				documents = append(documents, []byte(document))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q; use %q or %q", format, formatJSON, formatText)
+	}
+
+	if len(documents) == 0 {
+		return nil, errors.New("no messages to publish")
+	}
+
+	messages := make([]proto.Message, 0, len(documents))
+
+	for i, document := range documents {
+		message := proto.Clone(prototype)
+		var err error
+
+		if format == formatJSON {
+			err = protojson.Unmarshal(document, message)
+		} else {
+			err = prototext.Unmarshal(document, message)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse message %d: %w", i+1, err)
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// splitJSON splits input into the JSON objects it holds one after another, or into the elements of
+// the array it holds.
+func splitJSON(input []byte) ([][]byte, error) {
+	trimmed := bytes.TrimSpace(input)
+
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var elements []json.RawMessage
+
+		if err := json.Unmarshal(trimmed, &elements); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON array: %w", err)
+		}
+
+		documents := make([][]byte, len(elements))
+
+		for i, element := range elements {
+			documents[i] = element
+		}
+
+		return documents, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	documents := make([][]byte, 0)
+
+	for {
+		var document json.RawMessage
+
+		if err := decoder.Decode(&document); err == io.EOF {
+			return documents, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse message %d: %w", len(documents)+1, err)
+		}
+
+		documents = append(documents, document)
+	}
+}
+
+// validationProblems validates a copy of message the way the worker does with policy, and describes its
+// problems. It returns an empty string for a valid message.
+func validationProblems(message proto.Message, policy validation.Policy) string {
+	normalized := proto.Clone(message)
+	validation.Normalize(normalized)
+
+	result := validation.ValidateWith(normalized, policy)
+
+	if result.Valid {
+		return ""
+	}
+
+	return strings.Join(result.Errors.Messages(), "; ")
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"github.com/google/uuid"
+	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"kwekker-worker/pkg/validation"
+	"strings"
+	"testing"
+)
+
+func TestParseMessagesFromJSONStreamAndArray(t *testing.T) {
+	inputs := []string{
+		`{"kwekGuid": "a"} {"kwekGuid": "b"}`,
+		"{\"kwekGuid\": \"a\"}\n{\"kwekGuid\": \"b\"}\n",
+		`[{"kwekGuid": "a"}, {"kwekGuid": "b"}]`,
+	}
+
+	for _, input := range inputs {
+		messages, err := parseMessages([]byte(input), formatJSON, &kwekproto.DeleteKwek{})
+
+		if err != nil {
+			t.Errorf("Messages in %q should be parsed, but are not: %v", input, err)
+			continue
+		}
+
+		if len(messages) != 2 || messages[1].(*kwekproto.DeleteKwek).GetKwekGuid() != "b" {
+			t.Errorf("Input %q should hold two messages, but holds %v", input, messages)
+		}
+	}
+}
+
+func TestParseMessagesFromText(t *testing.T) {
+	documents := make([]string, 0)
+
+	for _, guid := range []string{"a", "b", "c"} {
+		document, err := prototext.Marshal(&kwekproto.DeleteKwek{KwekGuid: guid})
+
+		if err != nil {
+			t.Fatalf("Message should be marshalled, but is not: %v", err)
+		}
+
+		documents = append(documents, string(document))
+	}
+
+	input := strings.Join(documents, "\n---\n") + "\n---\n"
+	messages, err := parseMessages([]byte(input), formatText, &kwekproto.DeleteKwek{})
+
+	if err != nil {
+		t.Fatalf("Messages should be parsed, but are not: %v", err)
+	}
+
+	if len(messages) != 3 || messages[2].(*kwekproto.DeleteKwek).GetKwekGuid() != "c" {
+		t.Errorf("Input should hold three messages, but holds %v", messages)
+	}
+}
+
+func TestParseMessagesRejectsInvalidInput(t *testing.T) {
+	inputs := map[string]string{
+		"empty":         "  \n",
+		"unknown field": `{"kwekGuid": "a"} {"colour": "blue"}`,
+		"truncated":     `{"kwekGuid": "a"`,
+	}
+
+	for name, input := range inputs {
+		if _, err := parseMessages([]byte(input), formatJSON, &kwekproto.DeleteKwek{}); err == nil {
+			t.Errorf("Input %s should be rejected, but is not", name)
+		}
+	}
+}
+
+func TestValidationProblemsDescribesInvalidMessage(t *testing.T) {
+	if problems := validationProblems(&kwekproto.DeleteKwek{KwekGuid: "not a guid"}, validation.DefaultPolicy()); problems == "" {
+		t.Errorf("Message with an invalid GUID should have problems, but has none")
+	}
+
+	if problems := validationProblems(&kwekproto.DeleteKwek{KwekGuid: "12230DAF-29EE-47E0-B957-905E7731E12A"}, validation.DefaultPolicy()); problems != "" {
+		t.Errorf("Message with an uppercase GUID should be valid after normalizing, but has problems: %s", problems)
+	}
+}
+
+func TestValidationProblemsUseConfiguredLimits(t *testing.T) {
+	previous := validation.CurrentPolicy()
+	t.Cleanup(func() { _ = validation.SetPolicy(previous) })
+	t.Setenv("VALIDATION_TEXT_MAX_LENGTH", "5")
+
+	if _, err := loadEnvironment(); err != nil {
+		t.Fatalf("Environment should be loaded, but is not: %v", err)
+	}
+
+	kwek := &kwekproto.CreateKwek{KwekGuid: uuid.NewString(), Text: "Hello world", UserId: uuid.NewString(), PostedAt: timestamppb.Now()}
+
+	if problems := validationProblems(kwek, validation.DefaultPolicy()); problems != "" {
+		t.Fatalf("Kwek should be valid with the default limits, but has problems: %s", problems)
+	}
+
+	if problems := validationProblems(kwek, validation.CurrentPolicy()); problems == "" {
+		t.Errorf("Kwek longer than the configured 5 characters should have problems, but has none")
+	}
+}
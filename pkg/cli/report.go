@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"kwekker-worker/pkg/db"
+	"kwekker-worker/pkg/latency"
+	"text/tabwriter"
+	"time"
+)
+
+func newReportCommand() *cobra.Command {
+	var (
+		since time.Duration
+		queue string
+	)
+
+	command := &cobra.Command{
+		Use:   "report",
+		Short: "Report the publish-to-commit latency of recently handled messages per queue",
+		Long: `Report the publish-to-commit latency of recently handled messages per queue.
+
+The worker records the latency of every message it commits that carries the time it was published,
+which the publish and load commands stamp on their messages. Latencies are reported as the 50th,
+95th and 99th percentile and the maximum. The worker deletes latencies older than LATENCY_RETENTION,
+so --since cannot reach further back than that.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if queue != "" && !isKnownQueue(queue) {
+				return fmt.Errorf("unknown queue %q", queue)
+			}
+
+			env, err := loadEnvironment()
+
+			if err != nil {
+				return err
+			}
+
+			conn := db.NewDB(env.logger, env.config.Postgres).Connect()
+			defer conn.Close(context.Background())
+
+			samples, err := db.LatencySamples(context.Background(), conn, time.Now().Add(-since), queue)
+
+			if err != nil {
+				return err
+			}
+
+			if len(samples) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "No latencies were recorded in the last %s\n", since)
+				return nil
+			}
+
+			return printLatencies(cmd, latency.Summarize(samples))
+		},
+	}
+
+	command.Flags().DurationVar(&since, "since", time.Hour, "how far back to report messages committed")
+	command.Flags().StringVar(&queue, "queue", "", "only report the messages of this queue")
+
+	return command
+}
+
+func printLatencies(cmd *cobra.Command, summaries []latency.Summary) error {
+	writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "QUEUE\tCOUNT\tP50\tP95\tP99\tMAX\t")
+
+	for _, summary := range summaries {
+		fmt.Fprintf(
+			writer,
+			"%s\t%d\t%s\t%s\t%s\t%s\t\n",
+			summary.Queue,
+			summary.Count,
+			summary.P50.Round(time.Microsecond),
+			summary.P95.Round(time.Microsecond),
+			summary.P99.Round(time.Microsecond),
+			summary.Max.Round(time.Microsecond),
+		)
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"kwekker-worker/pkg/config"
+	"kwekker-worker/pkg/logging"
+	"kwekker-worker/pkg/rabbitmq"
+	"kwekker-worker/pkg/validation"
+	"sort"
+	"strings"
+)
+
+// Execute runs the command named by the arguments of the process, and returns its error after printing it.
+func Execute() error {
+	return newRootCommand().Execute()
+}
+
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:   "kwekker-worker",
+		Short: "Consumes kwek and user messages from RabbitMQ and stores them in Postgres",
+		// Usage is only useful for mistakes in the arguments, which cobra reports before running a command.
+		SilenceUsage: true,
+	}
+
+	root.AddCommand(
+		newRunCommand(),
+		newMigrateCommand(),
+		newPublishCommand(),
+		newLoadCommand(),
+		newDlqCommand(),
+		newReportCommand(),
+		newConfigCommand(),
+		newTopologyCommand(),
+		newSearchCommand(),
+	)
+
+	return root
+}
+
+// environment holds what most commands need: the validated configuration and a logger built from it.
+type environment struct {
+	config *config.Config
+	logger *zap.SugaredLogger
+	levels *logging.Levels
+}
+
+func loadEnvironment() (*environment, error) {
+	conf, err := config.LoadConfig()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to load configuration; is the .env file present and valid? %w", err)
+	}
+
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	logger, levels, err := logging.New(conf.Logging)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Every command validates messages with the configured limits, the worker as well as publish --validate.
+	if err = validation.SetPolicy(validation.NewPolicy(conf.Validation)); err != nil {
+		return nil, err
+	}
+
+	return &environment{config: conf, logger: logger.Sugar(), levels: levels}, nil
+}
+
+// openChannel connects to RabbitMQ and opens a channel. Closing the connection closes the channel as well.
+func (e *environment) openChannel() (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := rabbitmq.Dial(e.config.RabbitMQ)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	mqchannel, err := conn.Channel()
+
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	return conn, mqchannel, nil
+}
+
+// queue returns the settings of the queue with the given name, such as kwek.create.
+func (e *environment) queue(name string) (config.QueueData, error) {
+	queueData, ok := e.config.Queues[name]
+
+	if !ok {
+		return config.QueueData{}, fmt.Errorf("unknown queue %q; known queues are %s", name, strings.Join(queueNames(), ", "))
+	}
+
+	return queueData, nil
+}
+
+// queueNames returns the names of the queues in config.QueueList, sorted.
+func queueNames() []string {
+	names := make([]string, 0, len(config.QueueList))
+
+	for name := range config.QueueList {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
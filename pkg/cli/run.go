@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"kwekker-worker/pkg/worker"
+)
+
+func newRunCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "run",
+		Short: "Run the worker",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := loadEnvironment()
+
+			if err != nil {
+				return err
+			}
+
+			defer env.logger.Sync()
+
+			w := worker.NewWorker(env.logger, *env.config)
+			w.UseLogLevels(env.levels)
+			w.Initialize()
+
+			return nil
+		},
+	}
+}
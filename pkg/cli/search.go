@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"kwekker-worker/pkg/db"
+)
+
+func newSearchCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "search",
+		Short: "Maintain the full-text search index of kweks",
+	}
+
+	var (
+		all       bool
+		batchSize int
+		language  string
+	)
+
+	backfill := &cobra.Command{
+		Use:   "backfill",
+		Short: "Index the kweks that have no search vector yet",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// A batch of no kweks would never get through them.
+			if batchSize < 1 {
+				return errors.New("--batch-size must be at least 1")
+			}
+
+			env, err := loadEnvironment()
+
+			if err != nil {
+				return err
+			}
+
+			if language == "" {
+				language = env.config.Search.Language
+			}
+
+			conn := db.NewDB(env.logger, env.config.Postgres).Connect()
+			defer conn.Close(context.Background())
+
+			updated, err := db.BackfillSearchVectors(context.Background(), conn, language, batchSize, all)
+
+			if err != nil {
+				return fmt.Errorf("failed to backfill search vectors after updating %d kweks: %w", updated, err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Indexed %d kweks using the %q text search configuration\n", updated, language)
+
+			return nil
+		},
+	}
+
+	backfill.Flags().BoolVar(&all, "all", false, "re-index every kwek instead of only the ones without a search vector")
+	backfill.Flags().IntVar(&batchSize, "batch-size", 1000, "number of kweks to update per statement")
+	backfill.Flags().StringVar(&language, "language", "", "text search configuration to use; defaults to SEARCH_LANGUAGE")
+
+	command.AddCommand(backfill)
+
+	return command
+}
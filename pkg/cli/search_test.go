@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSearchBackfillRejectsEmptyBatches(t *testing.T) {
+	for _, batchSize := range []string{"0", "-1"} {
+		command := newSearchCommand()
+		command.SetArgs([]string{"backfill", "--batch-size", batchSize})
+		command.SetOut(io.Discard)
+		command.SetErr(io.Discard)
+
+		if err := command.Execute(); err == nil || !strings.Contains(err.Error(), "--batch-size") {
+			t.Errorf("Batch size %s should be rejected, but the error is %v", batchSize, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"kwekker-worker/pkg/rabbitmq"
+	"text/tabwriter"
+)
+
+func newTopologyCommand() *cobra.Command {
+	var declare bool
+
+	command := &cobra.Command{
+		Use:   "topology",
+		Short: "Print the exchanges, queues and bindings of the enabled queues, and optionally declare them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := loadEnvironment()
+
+			if err != nil {
+				return err
+			}
+
+			queues := env.config.Queues.Enabled()
+			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "EXCHANGE\tROUTING KEY\tQUEUE\tDEAD-LETTER QUEUE")
+
+			for _, queue := range queueNames() {
+				if queueData, ok := queues[queue]; ok {
+					fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", queueData.Exchange, queueData.RoutingKey, queue, rabbitmq.DeadLetterQueue(queue))
+				}
+			}
+
+			if err = writer.Flush(); err != nil || !declare {
+				return err
+			}
+
+			conn, mqchannel, err := env.openChannel()
+
+			if err != nil {
+				return err
+			}
+
+			defer conn.Close()
+
+			if err = rabbitmq.DeclareTopology(mqchannel, queues); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "Declared the topology")
+
+			return nil
+		},
+	}
+
+	command.Flags().BoolVar(&declare, "declare", false, "declare the exchanges and queues, and bind them")
+
+	return command
+}
@@ -1,37 +1,214 @@
 package config
 
 import (
+	"fmt"
 	"github.com/spf13/viper"
+	"go.uber.org/zap/zapcore"
+	"os"
+	"sort"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	RabbitMQ RabbitMQConfig `mapstructure:",squash"`
-	Postgres PostgresConfig `mapstructure:",squash"`
+	RabbitMQ   RabbitMQConfig   `mapstructure:",squash"`
+	Postgres   PostgresConfig   `mapstructure:",squash"`
+	Search     SearchConfig     `mapstructure:",squash"`
+	Moderation ModerationConfig `mapstructure:",squash"`
+	RateLimit  RateLimitConfig  `mapstructure:",squash"`
+	Metrics    MetricsConfig    `mapstructure:",squash"`
+	Latency    LatencyConfig    `mapstructure:",squash"`
+	Validation ValidationConfig `mapstructure:",squash"`
+	DryRun     DryRunConfig     `mapstructure:",squash"`
+	Logging    LoggingConfig    `mapstructure:",squash"`
+	// Queues holds the settings of every known queue, including disabled ones. See loadQueues.
+	Queues Queues `mapstructure:"-"`
 }
 
 type RabbitMQConfig struct {
+	// URL is a complete amqp:// or amqps:// URL; when set, the host, port, vhost and credentials below are ignored.
+	URL      string `mapstructure:"RABBITMQ_URL"`
 	Username string `mapstructure:"RABBITMQ_USER"`
 	Password string `mapstructure:"RABBITMQ_PASS"`
 	Host     string `mapstructure:"RABBITMQ_HOST"`
 	Port     uint16 `mapstructure:"RABBITMQ_PORT"`
 	Vhost    string `mapstructure:"RABBITMQ_VHOST"`
+	// TLS connects over amqps:// when the URL is built from the settings above.
+	TLS bool `mapstructure:"RABBITMQ_TLS"`
+	// CAFile is a PEM file with the certificate authorities to verify the broker with, instead of the system ones.
+	CAFile string `mapstructure:"RABBITMQ_TLS_CA_FILE"`
+	// CertFile and KeyFile are a PEM client certificate and its key, for brokers that authenticate clients by certificate.
+	CertFile string `mapstructure:"RABBITMQ_TLS_CERT_FILE"`
+	KeyFile  string `mapstructure:"RABBITMQ_TLS_KEY_FILE"`
 }
 
 type PostgresConfig struct {
+	// URL is a complete postgres:// URL or key/value connection string; when set, the settings below are ignored.
+	URL      string `mapstructure:"DATABASE_URL"`
 	Username string `mapstructure:"POSTGRES_USER"`
 	Password string `mapstructure:"POSTGRES_PASSWORD"`
 	Host     string `mapstructure:"POSTGRES_HOST"`
 	Port     uint16 `mapstructure:"POSTGRES_PORT"`
 	Database string `mapstructure:"POSTGRES_DB"`
+	// SSLMode is one of disable, allow, prefer, require, verify-ca or verify-full; empty leaves the default of prefer.
+	SSLMode string `mapstructure:"POSTGRES_SSLMODE"`
+	// SSLRootCert is a PEM file with the certificate authorities to verify the server with.
+	SSLRootCert string `mapstructure:"POSTGRES_SSLROOTCERT"`
+	// SSLCert and SSLKey are a PEM client certificate and its key.
+	SSLCert string `mapstructure:"POSTGRES_SSLCERT"`
+	SSLKey  string `mapstructure:"POSTGRES_SSLKEY"`
 }
 
+type SearchConfig struct {
+	// Language is the Postgres text search configuration used to index kweks, e.g. "simple" or "english".
+	Language string `mapstructure:"SEARCH_LANGUAGE"`
+}
+
+type ModerationConfig struct {
+	// BannedWordsFile is a file with one banned word per line; leave empty to disable the rule.
+	BannedWordsFile string `mapstructure:"MODERATION_BANNED_WORDS_FILE"`
+	// BlockedDomains is a comma-separated list of domains that kweks may not link to.
+	BlockedDomains []string `mapstructure:"MODERATION_BLOCKED_DOMAINS"`
+	// MaxCapsRatio is the share of uppercase letters above which a kwek is flagged; 0 disables the rule.
+	MaxCapsRatio float64 `mapstructure:"MODERATION_MAX_CAPS_RATIO"`
+	// MinCapsLetters is the number of letters a kwek needs before the caps rule applies.
+	MinCapsLetters int `mapstructure:"MODERATION_MIN_CAPS_LETTERS"`
+	// MaxRepeatedCharacters is how often a character may repeat in a row before a kwek is flagged; 0 disables the rule.
+	MaxRepeatedCharacters int `mapstructure:"MODERATION_MAX_REPEATED_CHARACTERS"`
+}
+
+type RateLimitConfig struct {
+	Enabled        bool    `mapstructure:"RATELIMIT_ENABLED"`
+	KweksPerMinute float64 `mapstructure:"RATELIMIT_KWEKS_PER_MINUTE"`
+	Burst          int     `mapstructure:"RATELIMIT_KWEK_BURST"`
+}
+
+type MetricsConfig struct {
+	// Address is where metrics are served over HTTP; leave empty to disable.
+	Address string `mapstructure:"METRICS_ADDRESS"`
+}
+
+type LatencyConfig struct {
+	// Retention is how long the worker keeps the latencies of committed messages for the report command;
+	// 0 keeps them forever.
+	Retention time.Duration `mapstructure:"LATENCY_RETENTION"`
+}
+
+type ValidationConfig struct {
+	TextMaxLength        int `mapstructure:"VALIDATION_TEXT_MAX_LENGTH"`
+	UsernameMinLength    int `mapstructure:"VALIDATION_USERNAME_MIN_LENGTH"`
+	UsernameMaxLength    int `mapstructure:"VALIDATION_USERNAME_MAX_LENGTH"`
+	DisplayNameMaxLength int `mapstructure:"VALIDATION_DISPLAY_NAME_MAX_LENGTH"`
+	AvatarUrlMaxLength   int `mapstructure:"VALIDATION_AVATAR_URL_MAX_LENGTH"`
+	TimestampMaxAgeDays  int `mapstructure:"VALIDATION_TIMESTAMP_MAX_AGE_DAYS"`
+	// TimestampFutureSkew allows for publishers whose clocks run ahead, e.g. "2s".
+	TimestampFutureSkew time.Duration `mapstructure:"VALIDATION_TIMESTAMP_FUTURE_SKEW"`
+	// GuidVersions is a comma-separated list of accepted GUID versions, e.g. "4,7"; empty accepts any version.
+	GuidVersions []int `mapstructure:"VALIDATION_GUID_VERSIONS"`
+	// ReservedUsernames is a comma-separated list of usernames that cannot be registered.
+	ReservedUsernames []string `mapstructure:"VALIDATION_RESERVED_USERNAMES"`
+	// AvatarHosts is a comma-separated list of hosts avatars may be served from. An entry starting with
+	// a dot, e.g. ".gravatar.com", also allows its subdomains. Any public host is allowed when empty.
+	AvatarHosts []string `mapstructure:"VALIDATION_AVATAR_HOSTS"`
+}
+
+// DefaultValidationConfig returns the limits that apply when none are configured. The default validation
+// policy is built from it too, so that validating without a loaded configuration checks the same limits.
+func DefaultValidationConfig() ValidationConfig {
+	return ValidationConfig{
+		TextMaxLength:        256,
+		UsernameMinLength:    3,
+		UsernameMaxLength:    15,
+		DisplayNameMaxLength: 30,
+		AvatarUrlMaxLength:   256,
+		TimestampMaxAgeDays:  30,
+		TimestampFutureSkew:  2 * time.Second,
+		GuidVersions:         []int{},
+		ReservedUsernames:    []string{"admin", "administrator", "kwekker", "moderator", "root", "support", "system"},
+		AvatarHosts:          []string{},
+	}
+}
+
+// Dry-run modes: consume copies of the messages from a shadow queue, or consume the queues themselves
+// and put every message back, until as many distinct messages have been seen as the queue held at the start.
+const (
+	DryRunShadow  = "shadow"
+	DryRunRequeue = "requeue"
+)
+
+type DryRunConfig struct {
+	// Enabled makes the worker report what it would do with each message, without changing anything.
+	Enabled bool `mapstructure:"DRY_RUN_ENABLED"`
+	// Mode is either "shadow" or "requeue".
+	Mode string `mapstructure:"DRY_RUN_MODE"`
+	// ExecuteSql runs the handlers in a transaction that is always rolled back, instead of stopping after validation.
+	ExecuteSql bool `mapstructure:"DRY_RUN_EXECUTE_SQL"`
+	// ReportInterval is how often the outcomes so far are logged; zero disables the log.
+	ReportInterval time.Duration `mapstructure:"DRY_RUN_REPORT_INTERVAL"`
+}
+
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+type LoggingConfig struct {
+	// Level is the minimum level of the messages that are logged, e.g. "debug" or "info".
+	Level string `mapstructure:"LOG_LEVEL"`
+	// Format is "json" for one JSON object per line, or "console" for colourised lines meant for people.
+	Format string `mapstructure:"LOG_FORMAT"`
+	// PackageLevels is a comma-separated list of levels for single packages that override Level,
+	// e.g. "rabbitmq=debug,db=warn". See ParsePackageLevels.
+	PackageLevels []string `mapstructure:"LOG_PACKAGE_LEVELS"`
+	// SamplingInitial is how many messages with the same level and text are logged each second before
+	// sampling starts, after which only every SamplingThereafter-th one is. Zero disables sampling.
+	SamplingInitial    int `mapstructure:"LOG_SAMPLING_INITIAL"`
+	SamplingThereafter int `mapstructure:"LOG_SAMPLING_THEREAFTER"`
+}
+
+// ParsePackageLevels parses the package levels, keyed by package name.
+func (c LoggingConfig) ParsePackageLevels() (map[string]zapcore.Level, error) {
+	levels := make(map[string]zapcore.Level, len(c.PackageLevels))
+
+	for _, entry := range c.PackageLevels {
+		name, level, found := strings.Cut(strings.TrimSpace(entry), "=")
+
+		if !found || name == "" {
+			return nil, fmt.Errorf("package level %q is not of the form package=level", entry)
+		}
+
+		parsed, err := zapcore.ParseLevel(level)
+
+		if err != nil {
+			return nil, fmt.Errorf("package level %q has an unknown level", entry)
+		}
+
+		levels[name] = parsed
+	}
+
+	return levels, nil
+}
+
+// LoadConfig merges, from lowest to highest precedence, the defaults, the optional config file (see
+// readConfigFile), the .env file, the environment and secret files (see readSecretFiles). The result
+// should be checked with Validate. It can be called again to reload the configuration.
 func LoadConfig() (*Config, error) {
 	config := Config{}
+	// Start from scratch, so that settings removed from a file since the last load fall back to their defaults.
+	viper.Reset()
+	configFile = ""
+	configFileKeys = make(map[string]string)
+	secretFiles = make(map[string]string)
 	viper.AddConfigPath(".")
 	viper.SetConfigName(".env")
 	viper.SetConfigType("env")
 
 	setDefaults()
+
+	if err := readConfigFile(); err != nil {
+		return nil, err
+	}
+
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err != nil {
@@ -40,25 +217,175 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	if err := readSecretFiles(); err != nil {
+		return nil, err
+	}
+
 	err := viper.Unmarshal(&config)
 
 	if err != nil {
 		return &config, err
 	}
 
+	config.Queues, err = loadQueues()
+
+	if err != nil {
+		return &config, err
+	}
+
 	return &config, nil
 }
 
+// readConfigFile applies the optional YAML or TOML file named by the CONFIG_FILE environment variable,
+// or else kwekker.yaml or kwekker.toml in the working directory if there is one. Its nested keys name
+// the same settings as the environment variables: rabbitmq.host sets RABBITMQ_HOST, and
+// queues.kwek.create.prefetch sets QUEUES_KWEK_CREATE_PREFETCH. Settings the file does not know are
+// reported as an error, so that typos do not go unnoticed.
+func readConfigFile() error {
+	file := viper.New()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		file.SetConfigFile(path)
+	} else {
+		file.AddConfigPath(".")
+		file.SetConfigName("kwekker")
+	}
+
+	if err := file.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return nil
+		}
+
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	configFile = file.ConfigFileUsed()
+
+	known := make(map[string]bool)
+
+	for _, key := range viper.AllKeys() {
+		known[key] = true
+	}
+
+	unknown := make([]string, 0)
+
+	for _, key := range file.AllKeys() {
+		flattened := strings.NewReplacer(".", "_", "-", "_").Replace(key)
+
+		if !known[flattened] {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		// Defaults have the lowest precedence in viper, which lets the .env file and the environment override the file.
+		viper.SetDefault(flattened, file.Get(key))
+		configFileKeys[flattened] = file.ConfigFileUsed()
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown settings in %s: %s", file.ConfigFileUsed(), strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// secretKeys are the settings that hold credentials. Each can be read from the file named by the same
+// setting suffixed with _FILE, and is redacted when the configuration is printed.
+var secretKeys = []string{"RABBITMQ_URL", "RABBITMQ_PASS", "DATABASE_URL", "POSTGRES_PASSWORD"}
+
+// readSecretFiles reads every secret whose _FILE setting names a file, such as a secret mounted by the
+// container platform. A trailing line break is removed, as most tools write one.
+func readSecretFiles() error {
+	for _, key := range secretKeys {
+		path := viper.GetString(key + "_FILE")
+
+		if path == "" {
+			continue
+		}
+
+		if viper.GetString(key) != "" {
+			return fmt.Errorf("%s and %s_FILE cannot both be set", key, key)
+		}
+
+		contents, err := os.ReadFile(path)
+
+		if err != nil {
+			return fmt.Errorf("failed to read %s_FILE: %w", key, err)
+		}
+
+		viper.Set(key, strings.TrimRight(string(contents), "\r\n"))
+		secretFiles[strings.ToLower(key)] = path
+	}
+
+	return nil
+}
+
 func setDefaults() {
+	for _, key := range secretKeys {
+		viper.SetDefault(key+"_FILE", "")
+	}
+
+	viper.SetDefault("RABBITMQ_URL", "")
 	viper.SetDefault("RABBITMQ_USER", "")
 	viper.SetDefault("RABBITMQ_PASS", "")
 	viper.SetDefault("RABBITMQ_HOST", "localhost")
 	viper.SetDefault("RABBITMQ_PORT", 5672)
 	viper.SetDefault("RABBITMQ_VHOST", "/")
+	viper.SetDefault("RABBITMQ_TLS", false)
+	viper.SetDefault("RABBITMQ_TLS_CA_FILE", "")
+	viper.SetDefault("RABBITMQ_TLS_CERT_FILE", "")
+	viper.SetDefault("RABBITMQ_TLS_KEY_FILE", "")
 
+	viper.SetDefault("DATABASE_URL", "")
 	viper.SetDefault("POSTGRES_USER", "")
 	viper.SetDefault("POSTGRES_PASSWORD", "")
 	viper.SetDefault("POSTGRES_HOST", "localhost")
 	viper.SetDefault("POSTGRES_PORT", 5432)
 	viper.SetDefault("POSTGRES_DB", "")
+	viper.SetDefault("POSTGRES_SSLMODE", "")
+	viper.SetDefault("POSTGRES_SSLROOTCERT", "")
+	viper.SetDefault("POSTGRES_SSLCERT", "")
+	viper.SetDefault("POSTGRES_SSLKEY", "")
+
+	viper.SetDefault("SEARCH_LANGUAGE", "simple")
+
+	viper.SetDefault("MODERATION_BANNED_WORDS_FILE", "")
+	viper.SetDefault("MODERATION_BLOCKED_DOMAINS", "")
+	viper.SetDefault("MODERATION_MAX_CAPS_RATIO", 0.7)
+	viper.SetDefault("MODERATION_MIN_CAPS_LETTERS", 10)
+	viper.SetDefault("MODERATION_MAX_REPEATED_CHARACTERS", 10)
+
+	viper.SetDefault("RATELIMIT_ENABLED", true)
+	viper.SetDefault("RATELIMIT_KWEKS_PER_MINUTE", 10)
+	viper.SetDefault("RATELIMIT_KWEK_BURST", 5)
+
+	viper.SetDefault("METRICS_ADDRESS", ":9090")
+
+	viper.SetDefault("LATENCY_RETENTION", 7*24*time.Hour)
+
+	validation := DefaultValidationConfig()
+	viper.SetDefault("VALIDATION_TEXT_MAX_LENGTH", validation.TextMaxLength)
+	viper.SetDefault("VALIDATION_USERNAME_MIN_LENGTH", validation.UsernameMinLength)
+	viper.SetDefault("VALIDATION_USERNAME_MAX_LENGTH", validation.UsernameMaxLength)
+	viper.SetDefault("VALIDATION_DISPLAY_NAME_MAX_LENGTH", validation.DisplayNameMaxLength)
+	viper.SetDefault("VALIDATION_AVATAR_URL_MAX_LENGTH", validation.AvatarUrlMaxLength)
+	viper.SetDefault("VALIDATION_TIMESTAMP_MAX_AGE_DAYS", validation.TimestampMaxAgeDays)
+	viper.SetDefault("VALIDATION_TIMESTAMP_FUTURE_SKEW", validation.TimestampFutureSkew)
+	viper.SetDefault("VALIDATION_GUID_VERSIONS", validation.GuidVersions)
+	viper.SetDefault("VALIDATION_RESERVED_USERNAMES", validation.ReservedUsernames)
+	viper.SetDefault("VALIDATION_AVATAR_HOSTS", validation.AvatarHosts)
+
+	viper.SetDefault("DRY_RUN_ENABLED", false)
+	viper.SetDefault("DRY_RUN_MODE", DryRunShadow)
+	viper.SetDefault("DRY_RUN_EXECUTE_SQL", false)
+	viper.SetDefault("DRY_RUN_REPORT_INTERVAL", time.Minute)
+
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_FORMAT", LogFormatJSON)
+	viper.SetDefault("LOG_PACKAGE_LEVELS", "")
+	viper.SetDefault("LOG_SAMPLING_INITIAL", 100)
+	viper.SetDefault("LOG_SAMPLING_THEREAFTER", 100)
+
+	setQueueDefaults()
 }
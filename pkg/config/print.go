@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	// configFileKeys maps the settings read from the config file to the path of that file.
+	configFileKeys = make(map[string]string)
+	// secretFiles maps the secrets read from a _FILE setting to the path of the file.
+	secretFiles = make(map[string]string)
+)
+
+// Setting is the effective value of a single setting and where that value came from.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Settings returns every setting of the configuration loaded last, sorted by key. Secrets are redacted,
+// keeping only the password-free parts of connection URLs, so that the result is safe to share.
+func Settings() []Setting {
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+
+	settings := make([]Setting, 0, len(keys))
+
+	for _, key := range keys {
+		value := fmt.Sprint(viper.Get(key))
+
+		if isSecret(key) {
+			value = redact(value)
+		}
+
+		settings = append(settings, Setting{Key: strings.ToUpper(key), Value: value, Source: source(key)})
+	}
+
+	return settings
+}
+
+func isSecret(key string) bool {
+	for _, secret := range secretKeys {
+		if strings.EqualFold(key, secret) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redact hides a secret. A URL keeps everything but its password, which is enough to debug most problems.
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	if parsed, err := url.Parse(value); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		if _, hasPassword := parsed.User.Password(); hasPassword {
+			parsed.User = url.UserPassword(parsed.User.Username(), "redacted")
+		}
+
+		return parsed.String()
+	}
+
+	return "<redacted>"
+}
+
+// source describes where the effective value of key came from, following the precedence of LoadConfig.
+func source(key string) string {
+	if path, ok := secretFiles[key]; ok {
+		return "secret file " + path
+	}
+
+	if _, ok := os.LookupEnv(strings.ToUpper(key)); ok {
+		return "env"
+	}
+
+	if viper.InConfig(key) {
+		return ".env"
+	}
+
+	if path, ok := configFileKeys[key]; ok {
+		return "file " + path
+	}
+
+	return "default"
+}
@@ -1,21 +1,57 @@
 package config
 
 import (
+	"fmt"
 	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
 	userproto "github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
 	"google.golang.org/protobuf/proto"
+	"strings"
+	"time"
 )
 
 type QueueData struct {
 	Exchange string
 	Type     proto.Message
+	// RoutingKey is the key the queue is bound to its exchange with.
+	RoutingKey string
+	// Prefetch limits how many unacknowledged messages are delivered at once; zero means no limit.
+	Prefetch int
+	// Enabled queues are consumed; disabled ones are left alone.
+	Enabled bool
+	Retry   RetryPolicy
+}
+
+// RetryPolicy decides how often a message whose handling failed unexpectedly is retried before it is
+// dead-lettered. Messages rejected for a known reason, such as a rate limit, are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a message is handled at most; 1 disables retries.
+	MaxAttempts int
+	// Delay is the time to wait between attempts.
+	Delay time.Duration
 }
 
 type Queues map[string]QueueData
 
+// Enabled returns the queues that should be consumed.
+func (q Queues) Enabled() Queues {
+	enabled := make(Queues, len(q))
+
+	for queue, queueData := range q {
+		if queueData.Enabled {
+			enabled[queue] = queueData
+		}
+	}
+
+	return enabled
+}
+
 const kwekExchange = "kwek-exchange"
 const userExchange = "user-exchange"
 
+// QueueList holds the queues the worker knows how to handle, with the message type each carries. Their
+// other settings default to the values set by setQueueDefaults and can be configured per queue.
 var QueueList = Queues{
 	"kwek.create": {Exchange: kwekExchange, Type: &kwekproto.CreateKwek{}},
 	"kwek.update": {Exchange: kwekExchange, Type: &kwekproto.UpdateKwek{}},
@@ -24,3 +60,73 @@ var QueueList = Queues{
 	"user.update": {Exchange: userExchange, Type: &userproto.UpdateUser{}},
 	"user.delete": {Exchange: userExchange, Type: &userproto.DeleteUser{}},
 }
+
+// queueSettings are the configurable settings of a queue, under the keys returned by QueueKeyPrefix.
+type queueSettings struct {
+	Exchange         string        `mapstructure:"EXCHANGE"`
+	RoutingKey       string        `mapstructure:"ROUTING_KEY"`
+	Prefetch         int           `mapstructure:"PREFETCH"`
+	Enabled          bool          `mapstructure:"ENABLED"`
+	RetryMaxAttempts int           `mapstructure:"RETRY_MAX_ATTEMPTS"`
+	RetryDelay       time.Duration `mapstructure:"RETRY_DELAY"`
+}
+
+// QueueKeyPrefix returns the prefix of the settings of queue, e.g. "QUEUES_KWEK_CREATE_" for kwek.create.
+func QueueKeyPrefix(queue string) string {
+	return "QUEUES_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(queue)) + "_"
+}
+
+func setQueueDefaults() {
+	for queue, queueData := range QueueList {
+		prefix := QueueKeyPrefix(queue)
+
+		viper.SetDefault(prefix+"EXCHANGE", queueData.Exchange)
+		viper.SetDefault(prefix+"ROUTING_KEY", queue)
+		viper.SetDefault(prefix+"PREFETCH", 0)
+		viper.SetDefault(prefix+"ENABLED", true)
+		viper.SetDefault(prefix+"RETRY_MAX_ATTEMPTS", 1)
+		viper.SetDefault(prefix+"RETRY_DELAY", time.Second)
+	}
+}
+
+// loadQueues reads the settings of every queue in QueueList, decoding them the same way viper.Unmarshal
+// decodes the rest of the configuration.
+func loadQueues() (Queues, error) {
+	queues := make(Queues, len(QueueList))
+
+	for queue, queueData := range QueueList {
+		prefix := QueueKeyPrefix(queue)
+		values := make(map[string]any)
+
+		for _, key := range []string{"EXCHANGE", "ROUTING_KEY", "PREFETCH", "ENABLED", "RETRY_MAX_ATTEMPTS", "RETRY_DELAY"} {
+			values[key] = viper.Get(prefix + key)
+		}
+
+		var settings queueSettings
+
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+			WeaklyTypedInput: true,
+			Result:           &settings,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err = decoder.Decode(values); err != nil {
+			return nil, fmt.Errorf("invalid settings for queue %s: %w", queue, err)
+		}
+
+		queues[queue] = QueueData{
+			Exchange:   settings.Exchange,
+			Type:       queueData.Type,
+			RoutingKey: settings.RoutingKey,
+			Prefetch:   settings.Prefetch,
+			Enabled:    settings.Enabled,
+			Retry:      RetryPolicy{MaxAttempts: settings.RetryMaxAttempts, Delay: settings.RetryDelay},
+		}
+	}
+
+	return queues, nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ConnectionURL returns URL if it is set, and otherwise builds an amqp:// or amqps:// URL from the
+// separate settings, escaping the credentials and the vhost.
+func (c RabbitMQConfig) ConnectionURL() string {
+	if c.URL != "" {
+		return c.URL
+	}
+
+	scheme := "amqp"
+
+	if c.TLS {
+		scheme = "amqps"
+	}
+
+	connectionURL := url.URL{
+		Scheme: scheme,
+		User:   url.UserPassword(c.Username, c.Password),
+		Host:   net.JoinHostPort(c.Host, strconv.Itoa(int(c.Port))),
+		Path:   "/",
+	}
+
+	// The vhost used to be appended to the URL as it was, so "/kwekker" names the vhost "kwekker". Only a slash
+	// after the first one is part of the name, and is escaped. The default vhost is "/" or empty.
+	if vhost := strings.TrimPrefix(c.Vhost, "/"); vhost != "" {
+		connectionURL.Path = "/" + vhost
+		connectionURL.RawPath = "/" + url.PathEscape(vhost)
+	}
+
+	return connectionURL.String()
+}
+
+// ConnectionURL returns URL if it is set, and otherwise builds a postgres:// URL from the separate
+// settings, escaping the credentials and passing on the SSL settings as parameters.
+func (c PostgresConfig) ConnectionURL() string {
+	if c.URL != "" {
+		return c.URL
+	}
+
+	parameters := url.Values{}
+
+	for name, value := range map[string]string{
+		"sslmode":     c.SSLMode,
+		"sslrootcert": c.SSLRootCert,
+		"sslcert":     c.SSLCert,
+		"sslkey":      c.SSLKey,
+	} {
+		if value != "" {
+			parameters.Set(name, value)
+		}
+	}
+
+	connectionURL := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.Username, c.Password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(int(c.Port))),
+		Path:     "/" + c.Database,
+		RawQuery: parameters.Encode(),
+	}
+
+	return connectionURL.String()
+}
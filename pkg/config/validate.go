@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap/zapcore"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Validate checks the merged configuration before anything connects. The returned error lists every
+// problem found, each naming the environment variable of the setting it is about.
+func (c *Config) Validate() error {
+	problems := make([]string, 0)
+
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.RabbitMQ.URL == "" && c.RabbitMQ.Host == "" {
+		problem("RABBITMQ_HOST or RABBITMQ_URL must be set")
+	}
+
+	if c.RabbitMQ.URL == "" && c.RabbitMQ.Port == 0 {
+		problem("RABBITMQ_PORT must be set")
+	}
+
+	if _, err := amqp.ParseURI(c.RabbitMQ.ConnectionURL()); err != nil {
+		problem("RabbitMQ connection URL is invalid: %s", err)
+	}
+
+	if (c.RabbitMQ.CertFile == "") != (c.RabbitMQ.KeyFile == "") {
+		problem("RABBITMQ_TLS_CERT_FILE and RABBITMQ_TLS_KEY_FILE must be set together")
+	}
+
+	if c.Postgres.URL == "" && c.Postgres.Host == "" {
+		problem("POSTGRES_HOST or DATABASE_URL must be set")
+	}
+
+	if c.Postgres.URL == "" && c.Postgres.Port == 0 {
+		problem("POSTGRES_PORT must be set")
+	}
+
+	// Parsing also checks the SSL mode and loads the certificate files. Its errors never include the password.
+	if _, err := pgx.ParseConfig(c.Postgres.ConnectionURL()); err != nil {
+		problem("Postgres connection settings are invalid: %s", err)
+	}
+
+	if _, err := zapcore.ParseLevel(c.Logging.Level); err != nil {
+		problem("LOG_LEVEL must be one of debug, info, warn, error, dpanic, panic or fatal, but is %q", c.Logging.Level)
+	}
+
+	if c.Logging.Format != LogFormatJSON && c.Logging.Format != LogFormatConsole {
+		problem("LOG_FORMAT must be %q or %q, but is %q", LogFormatJSON, LogFormatConsole, c.Logging.Format)
+	}
+
+	if _, err := c.Logging.ParsePackageLevels(); err != nil {
+		problem("LOG_PACKAGE_LEVELS is invalid: %s", err)
+	}
+
+	if c.Logging.SamplingInitial < 0 || c.Logging.SamplingThereafter < 0 {
+		problem("LOG_SAMPLING_INITIAL and LOG_SAMPLING_THEREAFTER cannot be negative")
+	}
+
+	if c.Metrics.Address != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Address); err != nil {
+			problem("METRICS_ADDRESS must be a host:port address such as \":9090\", but is %q", c.Metrics.Address)
+		}
+	}
+
+	if c.Latency.Retention < 0 {
+		problem("LATENCY_RETENTION cannot be negative, but is %s", c.Latency.Retention)
+	}
+
+	if c.Search.Language == "" {
+		problem("SEARCH_LANGUAGE must be set")
+	}
+
+	if c.Moderation.MaxCapsRatio < 0 || c.Moderation.MaxCapsRatio > 1 {
+		problem("MODERATION_MAX_CAPS_RATIO must be between 0 and 1, but is %g", c.Moderation.MaxCapsRatio)
+	}
+
+	if c.Moderation.MinCapsLetters < 0 {
+		problem("MODERATION_MIN_CAPS_LETTERS cannot be negative, but is %d", c.Moderation.MinCapsLetters)
+	}
+
+	if c.Moderation.MaxRepeatedCharacters < 0 {
+		problem("MODERATION_MAX_REPEATED_CHARACTERS cannot be negative, but is %d", c.Moderation.MaxRepeatedCharacters)
+	}
+
+	if c.RateLimit.Enabled && c.RateLimit.KweksPerMinute <= 0 {
+		problem("RATELIMIT_KWEKS_PER_MINUTE must be positive, but is %g", c.RateLimit.KweksPerMinute)
+	}
+
+	if c.RateLimit.Enabled && c.RateLimit.Burst < 1 {
+		problem("RATELIMIT_KWEK_BURST must be at least 1, but is %d", c.RateLimit.Burst)
+	}
+
+	if c.DryRun.Mode != DryRunShadow && c.DryRun.Mode != DryRunRequeue {
+		problem("DRY_RUN_MODE must be %q or %q, but is %q", DryRunShadow, DryRunRequeue, c.DryRun.Mode)
+	}
+
+	if c.DryRun.ReportInterval < 0 {
+		problem("DRY_RUN_REPORT_INTERVAL cannot be negative, but is %s", c.DryRun.ReportInterval)
+	}
+
+	problems = append(problems, c.Queues.problems()...)
+
+	if len(c.Queues.Enabled()) == 0 {
+		problem("at least one queue must be enabled")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+
+	return nil
+}
+
+func (q Queues) problems() []string {
+	names := make([]string, 0, len(q))
+
+	for queue := range q {
+		names = append(names, queue)
+	}
+
+	// Sort the queues so that the problems are listed in the same order every time.
+	sort.Strings(names)
+
+	problems := make([]string, 0)
+
+	for _, queue := range names {
+		queueData := q[queue]
+		prefix := QueueKeyPrefix(queue)
+
+		if queueData.Exchange == "" {
+			problems = append(problems, fmt.Sprintf("%sEXCHANGE must be set", prefix))
+		}
+
+		if queueData.RoutingKey == "" {
+			problems = append(problems, fmt.Sprintf("%sROUTING_KEY must be set", prefix))
+		}
+
+		if queueData.Prefetch < 0 {
+			problems = append(problems, fmt.Sprintf("%sPREFETCH cannot be negative, but is %d", prefix, queueData.Prefetch))
+		}
+
+		if queueData.Retry.MaxAttempts < 1 {
+			problems = append(problems, fmt.Sprintf("%sRETRY_MAX_ATTEMPTS must be at least 1, but is %d", prefix, queueData.Retry.MaxAttempts))
+		}
+
+		if queueData.Retry.Delay < 0 {
+			problems = append(problems, fmt.Sprintf("%sRETRY_DELAY cannot be negative, but is %s", prefix, queueData.Retry.Delay))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// configFile is the path of the config file read by the last LoadConfig, if there was one.
+var configFile string
+
+// settleDelay is how long Watcher waits for a burst of file events to end, since editors and
+// deployment tools often write a file in several steps.
+const settleDelay = 250 * time.Millisecond
+
+// Watcher reports changes to the files the configuration is read from.
+type Watcher struct {
+	// Changes receives a value once the watched files have changed. Changes in quick succession are reported once.
+	Changes <-chan struct{}
+	// Errors receives the errors of the underlying file system watcher.
+	Errors <-chan error
+
+	watcher *fsnotify.Watcher
+}
+
+// Close stops watching. Changes that were already noticed may still be reported.
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}
+
+// Watch watches the .env file and the config file, or the files LoadConfig looks for when there is no
+// config file yet. The directories holding them are watched rather than the files themselves, so that
+// files replaced by renaming a new version over them are still noticed.
+func Watch() (*Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{".env", "kwekker.yaml", "kwekker.toml"}
+
+	if configFile != "" {
+		paths = append(paths, configFile)
+	} else if path := os.Getenv("CONFIG_FILE"); path != "" {
+		paths = append(paths, path)
+	}
+
+	watched := make(map[string]bool, len(paths))
+
+	for _, path := range paths {
+		absolute, err := filepath.Abs(path)
+
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
+
+		if err = watcher.Add(filepath.Dir(absolute)); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+
+		watched[absolute] = true
+	}
+
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		var settled *time.Timer
+
+		for event := range watcher.Events {
+			if absolute, err := filepath.Abs(event.Name); err != nil || !watched[absolute] {
+				continue
+			}
+
+			if settled != nil {
+				settled.Stop()
+			}
+
+			settled = time.AfterFunc(settleDelay, func() {
+				// A change that is still waiting to be picked up covers this one as well.
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			})
+		}
+	}()
+
+	return &Watcher{Changes: changes, Errors: watcher.Errors, watcher: watcher}, nil
+}
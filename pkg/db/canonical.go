@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"kwekker-worker/pkg/validation"
+)
+
+// RecomputeCanonicalIdentities sets the canonical username and email address of every user to the ones the worker
+// computes with validation.CanonicalUsername and validation.CanonicalEmail. Migration 007 could only approximate
+// them in SQL, which leaves existing users that the worker would consider duplicates free to register again. It
+// returns the number of users that were updated, and fails without updating any when two users turn out to share
+// a canonical form.
+func RecomputeCanonicalIdentities(ctx context.Context, conn Transactor) (int, error) {
+	tx, err := conn.Begin(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT "Id", "Username", "Email", "UsernameCanonical", "EmailCanonical" FROM "Users"`)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	var (
+		ids       []int32
+		usernames []string
+		emails    []string
+	)
+
+	for rows.Next() {
+		var (
+			id                                                 int32
+			username, email, usernameCanonical, emailCanonical string
+		)
+
+		if err = rows.Scan(&id, &username, &email, &usernameCanonical, &emailCanonical); err != nil {
+			return 0, err
+		}
+
+		username, email = validation.CanonicalUsername(username), validation.CanonicalEmail(email)
+
+		if username != usernameCanonical || email != emailCanonical {
+			ids = append(ids, id)
+			usernames = append(usernames, username)
+			emails = append(emails, email)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// The unique indexes are checked row by row, so the outdated forms are cleared first; otherwise a user could
+	// take the new form of another before the other had given up its old one.
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE "Users" SET "UsernameCanonical" = '#' || "Id", "EmailCanonical" = '#' || "Id" WHERE "Id" = ANY($1)`,
+		ids,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE "Users" AS u
+			 SET "UsernameCanonical" = c."Username", "EmailCanonical" = c."Email"
+			 FROM unnest($1::integer[], $2::text[], $3::text[]) AS c("Id", "Username", "Email")
+			 WHERE u."Id" = c."Id"`,
+		ids,
+		usernames,
+		emails,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
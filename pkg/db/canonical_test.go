@@ -0,0 +1,50 @@
+package db_test
+
+import (
+	"context"
+	"kwekker-worker/pkg/db"
+	"kwekker-worker/pkg/db/dbtest"
+	"kwekker-worker/pkg/validation"
+	"testing"
+)
+
+func TestRecomputeCanonicalIdentitiesMatchesTheWorker(t *testing.T) {
+	conn := dbtest.Connect(t)
+	ctx := context.Background()
+
+	// The canonical forms as migration 007 approximated them.
+	_, err := conn.Exec(
+		ctx,
+		`INSERT INTO "Users" ("ProviderId", "Username", "Email", "UsernameCanonical", "EmailCanonical", "DisplayName", "AvatarUrl")
+			 VALUES ('provider|1', 'B0b', 'Bob@Example.com', 'b0b', 'Bob@example.com', 'Bob', ''),
+			        ('provider|2', 'alice', 'alice@example.com', 'alice', 'alice@example.com', 'Alice', '')`,
+	)
+
+	if err != nil {
+		t.Fatalf("Failed to insert users: %v", err)
+	}
+
+	recomputed, err := db.RecomputeCanonicalIdentities(ctx, conn)
+
+	if err != nil {
+		t.Fatalf("Canonical identities should be recomputed, but are not: %v", err)
+	}
+
+	if recomputed != 1 {
+		t.Errorf("Only the user whose canonical username differs should be updated, but %d are", recomputed)
+	}
+
+	var canonical string
+
+	if err = conn.QueryRow(ctx, `SELECT "UsernameCanonical" FROM "Users" WHERE "Username" = 'B0b'`).Scan(&canonical); err != nil {
+		t.Fatalf("Failed to read user: %v", err)
+	}
+
+	if canonical != validation.CanonicalUsername("B0b") {
+		t.Errorf("Canonical username should be %q, but is %q", validation.CanonicalUsername("B0b"), canonical)
+	}
+
+	if recomputed, err = db.RecomputeCanonicalIdentities(ctx, conn); err != nil || recomputed != 0 {
+		t.Errorf("Recomputing again should change nothing, but updated %d users: %v", recomputed, err)
+	}
+}
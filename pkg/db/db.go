@@ -2,7 +2,6 @@ package db
 
 import (
 	"context"
-	"fmt"
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 	"kwekker-worker/pkg/config"
@@ -16,7 +15,7 @@ type DB struct {
 
 func NewDB(logger *zap.SugaredLogger, config config.PostgresConfig) *DB {
 	return &DB{
-		logger: logger,
+		logger: logger.Named("db"),
 		config: config,
 	}
 }
@@ -28,23 +27,13 @@ func (db *DB) Connect() *pgx.Conn {
 
 	for i := 0; i < 5; i++ {
 		var err error
-		conn, err = pgx.Connect(
-			context.Background(),
-			fmt.Sprintf(
-				"postgres://%s:%s@%s:%d/%s",
-				db.config.Username,
-				db.config.Password,
-				db.config.Host,
-				db.config.Port,
-				db.config.Database,
-			),
-		)
+		conn, err = pgx.Connect(context.Background(), db.config.ConnectionURL())
 
 		if err == nil {
 			break
 		}
 
-		db.logger.Debug("Retrying database connection", "error", err)
+		db.logger.Debug("Retrying database connection", zap.Error(err))
 		time.Sleep(5 * time.Second)
 	}
 
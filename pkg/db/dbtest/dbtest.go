@@ -0,0 +1,49 @@
+// Package dbtest gives tests a database with the schema the migrations of the worker create.
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"kwekker-worker/pkg/db"
+	"os"
+	"testing"
+	"time"
+)
+
+// Connect connects to the database in KWEKKER_TEST_DATABASE_URL and applies the migrations to a schema of the
+// test's own, which the connection uses and which is dropped when the test ends. It skips the test when
+// KWEKKER_TEST_DATABASE_URL is not set.
+func Connect(t testing.TB) *pgx.Conn {
+	t.Helper()
+
+	url := os.Getenv("KWEKKER_TEST_DATABASE_URL")
+
+	if url == "" {
+		t.Skip("KWEKKER_TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, url)
+
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close(ctx) })
+
+	// The packages are tested in parallel processes, which may start a test at the same time.
+	schema := fmt.Sprintf("test_%d_%d", os.Getpid(), time.Now().UnixNano())
+
+	if _, err = conn.Exec(ctx, fmt.Sprintf(`CREATE SCHEMA %s; SET search_path TO %s`, schema, schema)); err != nil {
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Exec(ctx, fmt.Sprintf(`DROP SCHEMA %s CASCADE`, schema)) })
+
+	if _, err = db.Migrate(ctx, conn, ""); err != nil {
+		t.Fatalf("Failed to migrate test schema: %v", err)
+	}
+
+	return conn
+}
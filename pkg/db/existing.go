@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+)
+
+// ExistingEntities returns which of the users with the given provider IDs and which of the kweks with the given
+// GUIDs are stored.
+func ExistingEntities(ctx context.Context, conn Querier, userIds []string, kwekGuids []string) (map[string]bool, error) {
+	rows, err := conn.Query(
+		ctx,
+		`SELECT "ProviderId" FROM "Users" WHERE "ProviderId" = ANY($1::text[])
+		 UNION ALL
+		 SELECT "Guid"::text FROM "Kweks" WHERE "Guid" = ANY($2::uuid[])`,
+		userIds,
+		kwekGuids,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+
+	for rows.Next() {
+		var id string
+
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		existing[id] = true
+	}
+
+	return existing, rows.Err()
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"kwekker-worker/pkg/latency"
+	"time"
+)
+
+// RecordLatency records that a message published at publishedAt has just been committed.
+func RecordLatency(ctx context.Context, conn Querier, queue string, messageId string, publishedAt time.Time) error {
+	_, err := conn.Exec(
+		ctx,
+		`INSERT INTO "MessageLatencies" ("Queue", "MessageId", "PublishedAt", "CommittedAt")
+			 VALUES ($1, NULLIF($2, ''), $3, clock_timestamp())`,
+		queue,
+		messageId,
+		publishedAt,
+	)
+
+	return err
+}
+
+// DeleteLatenciesBefore deletes the latencies of the messages committed before the given time, and returns
+// how many it deleted.
+func DeleteLatenciesBefore(ctx context.Context, conn Querier, before time.Time) (int64, error) {
+	tag, err := conn.Exec(ctx, `DELETE FROM "MessageLatencies" WHERE "CommittedAt" < $1`, before)
+
+	return tag.RowsAffected(), err
+}
+
+// LatencySamples returns the latencies of the messages committed since the given time, of all queues
+// or only of queue when it is not empty.
+func LatencySamples(ctx context.Context, conn Querier, since time.Time, queue string) ([]latency.Sample, error) {
+	rows, err := conn.Query(
+		ctx,
+		`SELECT "Queue", COALESCE("MessageId", ''), "PublishedAt", "CommittedAt"
+			 FROM "MessageLatencies"
+			 WHERE "CommittedAt" >= $1 AND ($2 = '' OR "Queue" = $2)`,
+		since,
+		queue,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	samples := make([]latency.Sample, 0)
+
+	for rows.Next() {
+		var sample latency.Sample
+
+		if err = rows.Scan(&sample.Queue, &sample.MessageId, &sample.PublishedAt, &sample.CommittedAt); err != nil {
+			return nil, err
+		}
+
+		samples = append(samples, sample)
+	}
+
+	return samples, rows.Err()
+}
@@ -0,0 +1,52 @@
+package db_test
+
+import (
+	"context"
+	"kwekker-worker/pkg/db"
+	"kwekker-worker/pkg/db/dbtest"
+	"testing"
+	"time"
+)
+
+func TestLatencySamplesOfRecordedMessages(t *testing.T) {
+	conn := dbtest.Connect(t)
+	ctx := context.Background()
+	publishedAt := time.Now().Add(-time.Second)
+
+	for _, queue := range []string{"kwek.create", "kwek.create", "user.create"} {
+		if err := db.RecordLatency(ctx, conn, queue, "", publishedAt); err != nil {
+			t.Fatalf("Latency should be recorded, but is not: %v", err)
+		}
+	}
+
+	samples, err := db.LatencySamples(ctx, conn, time.Now().Add(-time.Minute), "kwek.create")
+
+	if err != nil {
+		t.Fatalf("Samples should be read, but are not: %v", err)
+	}
+
+	if len(samples) != 2 {
+		t.Fatalf("There should be two samples of kwek.create, but there are %d", len(samples))
+	}
+
+	if latency := samples[0].Latency(); latency < time.Second || latency > time.Minute {
+		t.Errorf("Latency should be about a second, but is %s", latency)
+	}
+}
+
+func TestDeleteLatenciesBeforeKeepsLaterOnes(t *testing.T) {
+	conn := dbtest.Connect(t)
+	ctx := context.Background()
+
+	if err := db.RecordLatency(ctx, conn, "kwek.create", "", time.Now()); err != nil {
+		t.Fatalf("Latency should be recorded, but is not: %v", err)
+	}
+
+	if deleted, err := db.DeleteLatenciesBefore(ctx, conn, time.Now().Add(-time.Minute)); err != nil || deleted != 0 {
+		t.Errorf("Latency committed after the given time should be kept, but %d were deleted (error: %v)", deleted, err)
+	}
+
+	if deleted, err := db.DeleteLatenciesBefore(ctx, conn, time.Now().Add(time.Minute)); err != nil || deleted != 1 {
+		t.Errorf("Latency committed before the given time should be deleted, but %d were (error: %v)", deleted, err)
+	}
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLock is the key of the advisory lock that keeps two replicas from migrating at the same time.
+const migrationLock = 7_301_984_117
+
+// Migration is a numbered SQL script that changes the schema. Its version is the name of its file
+// without the extension, e.g. "001_initial", and migrations are applied in the order of their versions.
+type Migration struct {
+	Version string
+	SQL     string
+}
+
+// Migrations returns the migrations embedded in the binary, oldest first.
+func Migrations() ([]Migration, error) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+
+	migrations := make([]Migration, 0, len(names))
+
+	for _, name := range names {
+		contents, err := migrationFiles.ReadFile(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+		migrations = append(migrations, Migration{Version: version, SQL: string(contents)})
+	}
+
+	return migrations, nil
+}
+
+// Migrate applies the migrations that have not been applied yet, and records each in the
+// "SchemaMigrations" table. It returns the versions it applied.
+//
+// Databases created before migrations were tracked already have the schema of some migrations. Passing
+// the last of those as baseline records it and the ones before it as applied without running them.
+func Migrate(ctx context.Context, conn *pgx.Conn, baseline string) ([]string, error) {
+	migrations, err := Migrations()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if baseline != "" && !hasVersion(migrations, baseline) {
+		return nil, fmt.Errorf("there is no migration %s to use as baseline", baseline)
+	}
+
+	if _, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+		return nil, err
+	}
+
+	defer conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, migrationLock)
+
+	_, err = conn.Exec(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS "SchemaMigrations" (
+			 "Version" text NOT NULL CONSTRAINT "PK_SchemaMigrations" PRIMARY KEY,
+			 "AppliedAt" timestamp with time zone NOT NULL DEFAULT now()
+		 )`,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(ctx, conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0)
+
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+
+		skip := baseline != "" && migration.Version <= baseline
+
+		// The scripts manage their own transaction, so the version is recorded once the script has committed.
+		if !skip {
+			if _, err = conn.Exec(ctx, migration.SQL); err != nil {
+				return versions, fmt.Errorf("failed to apply migration %s: %w", migration.Version, err)
+			}
+		}
+
+		_, err = conn.Exec(ctx, `INSERT INTO "SchemaMigrations" ("Version") VALUES ($1)`, migration.Version)
+
+		if err != nil {
+			return versions, fmt.Errorf("failed to record migration %s: %w", migration.Version, err)
+		}
+
+		if !skip {
+			versions = append(versions, migration.Version)
+		}
+	}
+
+	return versions, nil
+}
+
+func appliedVersions(ctx context.Context, conn Querier) (map[string]bool, error) {
+	rows, err := conn.Query(ctx, `SELECT "Version" FROM "SchemaMigrations"`)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+
+	for rows.Next() {
+		var version string
+
+		if err = rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+func hasVersion(migrations []Migration, version string) bool {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
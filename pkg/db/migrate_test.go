@@ -0,0 +1,28 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrationsAreEmbeddedInOrder(t *testing.T) {
+	migrations, err := Migrations()
+
+	if err != nil {
+		t.Fatalf("Migrations should be read, but are not: %v", err)
+	}
+
+	if len(migrations) == 0 || migrations[0].Version != "001_initial" {
+		t.Fatalf("First migration should be 001_initial, but migrations are %v", migrations)
+	}
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i-1].Version >= migrations[i].Version {
+			t.Errorf("Migration %s should come after %s, but does not", migrations[i].Version, migrations[i-1].Version)
+		}
+
+		if strings.TrimSpace(migrations[i].SQL) == "" {
+			t.Errorf("Migration %s should not be empty, but is", migrations[i].Version)
+		}
+	}
+}
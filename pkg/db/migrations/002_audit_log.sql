@@ -0,0 +1,36 @@
+START TRANSACTION;
+
+CREATE TABLE "AuditLog" (
+                            "Id" bigint GENERATED ALWAYS AS IDENTITY,
+                            "MessageType" text NOT NULL,
+                            "RoutingKey" text NOT NULL,
+                            "MessageId" text NULL,
+                            "EntityId" text NOT NULL,
+                            "Changes" jsonb NOT NULL,
+                            "AppliedAt" timestamp with time zone NOT NULL DEFAULT now(),
+                            CONSTRAINT "PK_AuditLog" PRIMARY KEY ("Id")
+);
+
+CREATE INDEX "IX_AuditLog_EntityId" ON "AuditLog" ("EntityId");
+
+-- The audit log is append-only: rows can be inserted, but never changed or removed.
+CREATE FUNCTION "RejectAuditLogModification"() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'AuditLog is append-only; % is not allowed', TG_OP;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "TR_AuditLog_RejectUpdateOrDelete"
+    BEFORE UPDATE OR DELETE
+    ON "AuditLog"
+    FOR EACH ROW
+EXECUTE FUNCTION "RejectAuditLogModification"();
+
+CREATE TRIGGER "TR_AuditLog_RejectTruncate"
+    BEFORE TRUNCATE
+    ON "AuditLog"
+    FOR EACH STATEMENT
+EXECUTE FUNCTION "RejectAuditLogModification"();
+
+COMMIT;
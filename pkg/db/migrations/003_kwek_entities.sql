@@ -0,0 +1,22 @@
+START TRANSACTION;
+
+CREATE TABLE "KwekHashtags" (
+                                "KwekId" integer NOT NULL,
+                                "Hashtag" text NOT NULL,
+                                CONSTRAINT "PK_KwekHashtags" PRIMARY KEY ("KwekId", "Hashtag"),
+                                CONSTRAINT "FK_KwekHashtags_Kweks_KwekId" FOREIGN KEY ("KwekId") REFERENCES "Kweks" ("Id") ON DELETE CASCADE
+);
+
+CREATE INDEX "IX_KwekHashtags_Hashtag" ON "KwekHashtags" ("Hashtag");
+
+CREATE TABLE "KwekMentions" (
+                                "KwekId" integer NOT NULL,
+                                "UserId" integer NOT NULL,
+                                CONSTRAINT "PK_KwekMentions" PRIMARY KEY ("KwekId", "UserId"),
+                                CONSTRAINT "FK_KwekMentions_Kweks_KwekId" FOREIGN KEY ("KwekId") REFERENCES "Kweks" ("Id") ON DELETE CASCADE,
+                                CONSTRAINT "FK_KwekMentions_Users_UserId" FOREIGN KEY ("UserId") REFERENCES "Users" ("Id") ON DELETE CASCADE
+);
+
+CREATE INDEX "IX_KwekMentions_UserId" ON "KwekMentions" ("UserId");
+
+COMMIT;
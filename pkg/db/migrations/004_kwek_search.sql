@@ -0,0 +1,9 @@
+START TRANSACTION;
+
+ALTER TABLE "Kweks"
+    ADD COLUMN "SearchLanguage" regconfig NOT NULL DEFAULT 'simple',
+    ADD COLUMN "SearchVector" tsvector NULL;
+
+CREATE INDEX "IX_Kweks_SearchVector" ON "Kweks" USING GIN ("SearchVector");
+
+COMMIT;
@@ -0,0 +1,23 @@
+START TRANSACTION;
+
+ALTER TABLE "Kweks"
+    ADD COLUMN "ModerationStatus" text NOT NULL DEFAULT 'allow'
+        CONSTRAINT "CK_Kweks_ModerationStatus" CHECK ("ModerationStatus" IN ('allow', 'flag'));
+
+-- Results are keyed by GUID rather than by kwek, so verdicts are kept for rejected kweks that were never stored.
+CREATE TABLE "KwekModerationResults" (
+                                         "Id" bigint GENERATED BY DEFAULT AS IDENTITY,
+                                         "KwekGuid" uuid NOT NULL,
+                                         "Rule" text NOT NULL,
+                                         "Verdict" text NOT NULL,
+                                         "Reason" text NULL,
+                                         "ModeratedAt" timestamp with time zone NOT NULL DEFAULT now(),
+                                         CONSTRAINT "PK_KwekModerationResults" PRIMARY KEY ("Id"),
+                                         CONSTRAINT "CK_KwekModerationResults_Verdict" CHECK ("Verdict" IN ('allow', 'flag', 'reject'))
+);
+
+CREATE INDEX "IX_KwekModerationResults_KwekGuid" ON "KwekModerationResults" ("KwekGuid");
+
+CREATE INDEX "IX_Kweks_ModerationStatus" ON "Kweks" ("ModerationStatus") WHERE "ModerationStatus" <> 'allow';
+
+COMMIT;
@@ -0,0 +1,10 @@
+START TRANSACTION;
+
+CREATE UNLOGGED TABLE "RateLimits" (
+                                       "Key" text NOT NULL,
+                                       "Tokens" double precision NOT NULL,
+                                       "UpdatedAt" timestamp with time zone NOT NULL,
+                                       CONSTRAINT "PK_RateLimits" PRIMARY KEY ("Key")
+);
+
+COMMIT;
@@ -0,0 +1,20 @@
+START TRANSACTION;
+
+-- Canonical forms of usernames and email addresses, as computed by the worker. Two users cannot share
+-- a canonical username or email address, even when the values they registered with differ.
+ALTER TABLE "Users" ADD COLUMN "UsernameCanonical" text NULL;
+ALTER TABLE "Users" ADD COLUMN "EmailCanonical" text NULL;
+
+-- Existing rows get an approximation of their canonical form; the worker rewrites it the next time the
+-- username or email address is updated. Duplicates among existing users must be resolved before this runs.
+UPDATE "Users"
+SET "UsernameCanonical" = lower("Username"),
+    "EmailCanonical" = substring("Email" FROM '^(.*@)') || lower(substring("Email" FROM '@([^@]*)$'));
+
+ALTER TABLE "Users" ALTER COLUMN "UsernameCanonical" SET NOT NULL;
+ALTER TABLE "Users" ALTER COLUMN "EmailCanonical" SET NOT NULL;
+
+CREATE UNIQUE INDEX "UX_Users_UsernameCanonical" ON "Users" ("UsernameCanonical");
+CREATE UNIQUE INDEX "UX_Users_EmailCanonical" ON "Users" ("EmailCanonical");
+
+COMMIT;
@@ -0,0 +1,15 @@
+START TRANSACTION;
+
+-- Publish-to-commit latency of the messages that carry a publish time, such as those of the load generator.
+CREATE TABLE "MessageLatencies" (
+                                    "Id" bigint GENERATED ALWAYS AS IDENTITY,
+                                    "Queue" text NOT NULL,
+                                    "MessageId" text NULL,
+                                    "PublishedAt" timestamp with time zone NOT NULL,
+                                    "CommittedAt" timestamp with time zone NOT NULL,
+                                    CONSTRAINT "PK_MessageLatencies" PRIMARY KEY ("Id")
+);
+
+CREATE INDEX "IX_MessageLatencies_CommittedAt" ON "MessageLatencies" ("CommittedAt");
+
+COMMIT;
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"time"
+)
+
+// Querier is implemented by both *pgx.Conn and pgx.Tx.
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Transactor is implemented by both *pgx.Conn and pgx.Tx. Beginning a transaction on a pgx.Tx creates
+// a savepoint, so code written against a Transactor can be run inside a transaction it does not control.
+type Transactor interface {
+	Querier
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type SearchResult struct {
+	Guid     string
+	Text     string
+	PostedAt time.Time
+	Rank     float32
+}
+
+// SearchKweks returns the kweks matching query, best match first. The query uses web search syntax
+// (quoted phrases, "or", "-" for negation) and is parsed with the given text search configuration,
+// which should be the one the kweks were indexed with.
+func SearchKweks(ctx context.Context, conn Querier, language string, query string, limit int) ([]SearchResult, error) {
+	rows, err := conn.Query(
+		ctx,
+		`SELECT "Guid"::text, "Text", "PostedAt", ts_rank("SearchVector", query) AS "Rank"
+			 FROM "Kweks", websearch_to_tsquery($1::regconfig, $2) query
+			 WHERE "SearchVector" @@ query
+			 ORDER BY "Rank" DESC, "PostedAt" DESC
+			 LIMIT $3`,
+		language,
+		query,
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	results := make([]SearchResult, 0)
+
+	for rows.Next() {
+		var result SearchResult
+
+		if err = rows.Scan(&result.Guid, &result.Text, &result.PostedAt, &result.Rank); err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// BackfillSearchVectors indexes existing kweks in batches of batchSize. Only kweks that have not been
+// indexed yet are processed, unless all is set, in which case every kwek is re-indexed with the given
+// language. It returns the number of kweks that were updated.
+func BackfillSearchVectors(ctx context.Context, conn Querier, language string, batchSize int, all bool) (int64, error) {
+	var (
+		total  int64
+		lastId int32
+	)
+
+	for {
+		var (
+			count int64
+			maxId *int32
+		)
+
+		err := conn.QueryRow(
+			ctx,
+			`WITH batch AS (
+				 UPDATE "Kweks"
+				 SET "SearchLanguage" = $1::regconfig, "SearchVector" = to_tsvector($1::regconfig, "Text")
+				 WHERE "Id" IN (
+					 SELECT "Id" FROM "Kweks"
+					 WHERE "Id" > $2 AND ($4 OR "SearchVector" IS NULL)
+					 ORDER BY "Id"
+					 LIMIT $3
+				 )
+				 RETURNING "Id"
+			 )
+			 SELECT count(*), max("Id") FROM batch`,
+			language,
+			lastId,
+			batchSize,
+			all,
+		).Scan(&count, &maxId)
+
+		if err != nil {
+			return total, err
+		}
+
+		if count == 0 || maxId == nil {
+			return total, nil
+		}
+
+		total += count
+		lastId = *maxId
+	}
+}
@@ -0,0 +1,108 @@
+package db_test
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"kwekker-worker/pkg/db"
+	"kwekker-worker/pkg/db/dbtest"
+	"testing"
+)
+
+// insertTestKweks stores a kwek with each of texts, posted by a user of their own, without a search vector.
+func insertTestKweks(t *testing.T, conn *pgx.Conn, texts ...string) {
+	ctx := context.Background()
+
+	_, err := conn.Exec(
+		ctx,
+		`INSERT INTO "Users" ("ProviderId", "Username", "UsernameCanonical", "Email", "EmailCanonical", "DisplayName", "AvatarUrl")
+			 VALUES ('search', 'search', 'search', 'search@example.com', 'search@example.com', 'Search', '')`,
+	)
+
+	if err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+
+	for _, text := range texts {
+		_, err = conn.Exec(
+			ctx,
+			`INSERT INTO "Kweks" ("Guid", "UserId", "Text", "PostedAt")
+				 VALUES (gen_random_uuid(), (SELECT "Id" FROM "Users" WHERE "ProviderId" = 'search'), $1, now())`,
+			text,
+		)
+
+		if err != nil {
+			t.Fatalf("Failed to insert kwek: %v", err)
+		}
+	}
+}
+
+func TestSearchKweksRanksBestMatchFirst(t *testing.T) {
+	conn := dbtest.Connect(t)
+	ctx := context.Background()
+
+	insertTestKweks(t, conn, "Running in the park", "I like cats", "Running late, running fast", "Dogs and cats")
+
+	if _, err := db.BackfillSearchVectors(ctx, conn, "english", 2, false); err != nil {
+		t.Fatalf("Backfill should succeed, but failed: %v", err)
+	}
+
+	results, err := db.SearchKweks(ctx, conn, "english", "run", 10)
+
+	if err != nil {
+		t.Fatalf("Search should succeed, but failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Search should find 2 kweks, but found %d", len(results))
+	}
+
+	if results[0].Text != "Running late, running fast" {
+		t.Errorf("Search should rank the kwek with the most matches first, but ranked %q first", results[0].Text)
+	}
+}
+
+func TestSearchKweksWithNegation(t *testing.T) {
+	conn := dbtest.Connect(t)
+	ctx := context.Background()
+
+	insertTestKweks(t, conn, "I like cats", "Dogs and cats")
+
+	if _, err := db.BackfillSearchVectors(ctx, conn, "english", 10, false); err != nil {
+		t.Fatalf("Backfill should succeed, but failed: %v", err)
+	}
+
+	results, err := db.SearchKweks(ctx, conn, "english", "cats -dogs", 10)
+
+	if err != nil {
+		t.Fatalf("Search should succeed, but failed: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Text != "I like cats" {
+		t.Errorf("Search should only find the kwek without dogs, but found %v", results)
+	}
+}
+
+func TestBackfillSearchVectorsOnlyIndexesMissingVectors(t *testing.T) {
+	conn := dbtest.Connect(t)
+	ctx := context.Background()
+
+	insertTestKweks(t, conn, "one", "two", "three")
+
+	updated, err := db.BackfillSearchVectors(ctx, conn, "simple", 2, false)
+
+	if err != nil || updated != 3 {
+		t.Fatalf("First backfill should index 3 kweks, but indexed %d (error: %v)", updated, err)
+	}
+
+	updated, err = db.BackfillSearchVectors(ctx, conn, "simple", 2, false)
+
+	if err != nil || updated != 0 {
+		t.Errorf("Second backfill should index no kweks, but indexed %d (error: %v)", updated, err)
+	}
+
+	updated, err = db.BackfillSearchVectors(ctx, conn, "simple", 2, true)
+
+	if err != nil || updated != 3 {
+		t.Errorf("Forced backfill should re-index 3 kweks, but indexed %d (error: %v)", updated, err)
+	}
+}
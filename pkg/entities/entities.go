@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Entities holds the structured data found in the text of a kwek.
+// Hashtags and mentions are lowercased, deduplicated and in order of first appearance,
+// without their leading '#' or '@'.
+type Entities struct {
+	Hashtags []string
+	Mentions []string
+}
+
+// Extract finds the #hashtags and @mentions in text. A marker only starts an entity when it is not
+// directly preceded by a word character, so e-mail addresses and things like "C#" are skipped.
+// Hashtags must contain at least one letter, so "#1" is not a hashtag.
+func Extract(text string) Entities {
+	entities := Entities{}
+	seenHashtags := make(map[string]bool)
+	seenMentions := make(map[string]bool)
+
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); i++ {
+		isHashtag := runes[i] == '#' || runes[i] == '＃'
+		isMention := runes[i] == '@' || runes[i] == '＠'
+
+		if !isHashtag && !isMention {
+			continue
+		}
+
+		if i > 0 && isWordRune(runes[i-1]) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+
+		if end == i+1 {
+			continue
+		}
+
+		word := strings.ToLower(string(runes[i+1 : end]))
+		i = end - 1
+
+		if isHashtag {
+			if strings.IndexFunc(word, unicode.IsLetter) < 0 || seenHashtags[word] {
+				continue
+			}
+
+			seenHashtags[word] = true
+			entities.Hashtags = append(entities.Hashtags, word)
+		} else {
+			if seenMentions[word] {
+				continue
+			}
+
+			seenMentions[word] = true
+			entities.Mentions = append(entities.Mentions, word)
+		}
+	}
+
+	return entities
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.In(r, unicode.L, unicode.M, unicode.Nd)
+}
@@ -0,0 +1,78 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractWithoutEntities(t *testing.T) {
+	entities := Extract("Hello world!")
+
+	if len(entities.Hashtags) != 0 {
+		t.Errorf("Extract should find no hashtags, but found %v", entities.Hashtags)
+	}
+
+	if len(entities.Mentions) != 0 {
+		t.Errorf("Extract should find no mentions, but found %v", entities.Mentions)
+	}
+}
+
+func TestExtractHashtags(t *testing.T) {
+	entities := Extract("#Go is great, #golang too! #GO")
+
+	expected := []string{"go", "golang"}
+
+	if !reflect.DeepEqual(entities.Hashtags, expected) {
+		t.Errorf("Extract should find hashtags %v, but found %v", expected, entities.Hashtags)
+	}
+}
+
+func TestExtractUnicodeHashtags(t *testing.T) {
+	entities := Extract("Привет #мир and #東京 and ＃café")
+
+	expected := []string{"мир", "東京", "café"}
+
+	if !reflect.DeepEqual(entities.Hashtags, expected) {
+		t.Errorf("Extract should find hashtags %v, but found %v", expected, entities.Hashtags)
+	}
+}
+
+func TestExtractIgnoresNumericHashtags(t *testing.T) {
+	entities := Extract("We're #1 and #2day")
+
+	expected := []string{"2day"}
+
+	if !reflect.DeepEqual(entities.Hashtags, expected) {
+		t.Errorf("Extract should find hashtags %v, but found %v", expected, entities.Hashtags)
+	}
+}
+
+func TestExtractIgnoresMarkersInsideWords(t *testing.T) {
+	entities := Extract("Mail foo@example.com about C# or a#b")
+
+	if len(entities.Hashtags) != 0 {
+		t.Errorf("Extract should find no hashtags, but found %v", entities.Hashtags)
+	}
+
+	if len(entities.Mentions) != 0 {
+		t.Errorf("Extract should find no mentions, but found %v", entities.Mentions)
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	entities := Extract("Hi @Alice, @bob_42 and @alice! (@Zoë)")
+
+	expected := []string{"alice", "bob_42", "zoë"}
+
+	if !reflect.DeepEqual(entities.Mentions, expected) {
+		t.Errorf("Extract should find mentions %v, but found %v", expected, entities.Mentions)
+	}
+}
+
+func TestExtractIgnoresBareMarkers(t *testing.T) {
+	entities := Extract("# @ #! @?")
+
+	if len(entities.Hashtags) != 0 || len(entities.Mentions) != 0 {
+		t.Errorf("Extract should find no entities, but found %v", entities)
+	}
+}
@@ -0,0 +1,79 @@
+package latency
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Sample is the publish-to-commit latency of a single message.
+type Sample struct {
+	Queue       string
+	MessageId   string
+	PublishedAt time.Time
+	CommittedAt time.Time
+}
+
+func (s Sample) Latency() time.Duration {
+	return s.CommittedAt.Sub(s.PublishedAt)
+}
+
+// Summary describes the latencies of the messages of a single queue.
+type Summary struct {
+	Queue string
+	Count int
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Summarize summarizes samples per queue, in the order of the queue names.
+func Summarize(samples []Sample) []Summary {
+	byQueue := make(map[string][]time.Duration)
+
+	for _, sample := range samples {
+		byQueue[sample.Queue] = append(byQueue[sample.Queue], sample.Latency())
+	}
+
+	summaries := make([]Summary, 0, len(byQueue))
+
+	for queue, latencies := range byQueue {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		summaries = append(summaries, Summary{
+			Queue: queue,
+			Count: len(latencies),
+			P50:   Percentile(latencies, 50),
+			P95:   Percentile(latencies, 95),
+			P99:   Percentile(latencies, 99),
+			Max:   latencies[len(latencies)-1],
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Queue < summaries[j].Queue })
+
+	return summaries
+}
+
+// Percentile returns the p-th percentile of sorted, which must be sorted in ascending order, using the
+// nearest-rank method: the smallest value that at least p percent of the values are less than or equal
+// to. It returns zero for no values.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	// The rank is rounded up, so that e.g. the 99th percentile of 10 values is the largest of them.
+	rank := int(math.Ceil(p * float64(len(sorted)) / 100))
+
+	if rank < 1 {
+		rank = 1
+	}
+
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+
+	return sorted[rank-1]
+}
@@ -0,0 +1,67 @@
+package latency
+
+import (
+	"testing"
+	"time"
+)
+
+func durations(milliseconds ...int) []time.Duration {
+	result := make([]time.Duration, len(milliseconds))
+
+	for i, millisecond := range milliseconds {
+		result[i] = time.Duration(millisecond) * time.Millisecond
+	}
+
+	return result
+}
+
+func TestPercentile(t *testing.T) {
+	cases := []struct {
+		values   []time.Duration
+		p        float64
+		expected time.Duration
+	}{
+		{nil, 50, 0},
+		{durations(7), 0, 7 * time.Millisecond},
+		{durations(7), 99, 7 * time.Millisecond},
+		{durations(15, 20, 35, 40, 50), 30, 20 * time.Millisecond},
+		{durations(15, 20, 35, 40, 50), 40, 20 * time.Millisecond},
+		{durations(15, 20, 35, 40, 50), 50, 35 * time.Millisecond},
+		{durations(15, 20, 35, 40, 50), 100, 50 * time.Millisecond},
+		{durations(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 95, 10 * time.Millisecond},
+		{durations(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 90, 9 * time.Millisecond},
+	}
+
+	for _, c := range cases {
+		if actual := Percentile(c.values, c.p); actual != c.expected {
+			t.Errorf("Percentile %g of %v should be %s, but is %s", c.p, c.values, c.expected, actual)
+		}
+	}
+}
+
+func TestSummarizeGroupsByQueue(t *testing.T) {
+	published := time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC)
+	samples := make([]Sample, 0)
+
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, Sample{Queue: "kwek.create", PublishedAt: published, CommittedAt: published.Add(time.Duration(i) * time.Millisecond)})
+	}
+
+	samples = append(samples, Sample{Queue: "user.create", PublishedAt: published, CommittedAt: published.Add(time.Second)})
+
+	summaries := Summarize(samples)
+
+	if len(summaries) != 2 || summaries[0].Queue != "kwek.create" || summaries[1].Queue != "user.create" {
+		t.Fatalf("Samples should be summarized per queue in order, but are %+v", summaries)
+	}
+
+	kweks := summaries[0]
+
+	if kweks.Count != 100 || kweks.P50 != 50*time.Millisecond || kweks.P95 != 95*time.Millisecond || kweks.P99 != 99*time.Millisecond || kweks.Max != 100*time.Millisecond {
+		t.Errorf("Summary of kwek.create should have percentiles 50, 95 and 99ms, but is %+v", kweks)
+	}
+
+	if summaries[1].P50 != time.Second {
+		t.Errorf("Summary of a single sample should have it as every percentile, but is %+v", summaries[1])
+	}
+}
@@ -0,0 +1,159 @@
+package logging
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/proto"
+	"kwekker-worker/pkg/config"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Keys of the fields that every log line about a message carries, see MessageFields.
+const (
+	QueueKey     = "queue"
+	MessageIdKey = "message_id"
+	EntityIdKey  = "entity_id"
+	TraceIdKey   = "trace_id"
+)
+
+// MessageFields returns the fields that identify a message in log lines, for use with With.
+func MessageFields(queue string, messageId string, entityId string, traceId string) []any {
+	return []any{
+		zap.String(QueueKey, queue),
+		zap.String(MessageIdKey, messageId),
+		zap.String(EntityIdKey, entityId),
+		zap.String(TraceIdKey, traceId),
+	}
+}
+
+// EntityId returns the GUID of the kwek or the ID of the user a message is about, or "" if it is about neither.
+func EntityId(protobuf proto.Message) string {
+	switch message := protobuf.(type) {
+	case interface{ GetKwekGuid() string }:
+		return message.GetKwekGuid()
+	case interface{ GetUserId() string }:
+		return message.GetUserId()
+	default:
+		return ""
+	}
+}
+
+// Levels holds the level of every logger created by New. Loggers are named after their package with
+// Named, which lets single packages log at a different level than the rest.
+type Levels struct {
+	current atomic.Pointer[levelSettings]
+}
+
+type levelSettings struct {
+	level    zapcore.Level
+	packages map[string]zapcore.Level
+	// minimum is the lowest level any logger logs at.
+	minimum zapcore.Level
+}
+
+// Set replaces the levels with those of conf. It is safe to call while messages are being logged.
+func (l *Levels) Set(conf config.LoggingConfig) error {
+	level, err := zapcore.ParseLevel(conf.Level)
+
+	if err != nil {
+		return err
+	}
+
+	packages, err := conf.ParsePackageLevels()
+
+	if err != nil {
+		return err
+	}
+
+	settings := &levelSettings{level: level, packages: packages, minimum: level}
+
+	for _, packageLevel := range packages {
+		if packageLevel < settings.minimum {
+			settings.minimum = packageLevel
+		}
+	}
+
+	l.current.Store(settings)
+
+	return nil
+}
+
+// Enabled reports whether the logger with the given name logs messages at level. A logger without a
+// level of its own uses that of its parent, so "rabbitmq.consumer" falls back to "rabbitmq".
+func (l *Levels) Enabled(name string, level zapcore.Level) bool {
+	settings := l.current.Load()
+
+	for name != "" {
+		if packageLevel, ok := settings.packages[name]; ok {
+			return packageLevel.Enabled(level)
+		}
+
+		cut := strings.LastIndex(name, ".")
+
+		if cut < 0 {
+			break
+		}
+
+		name = name[:cut]
+	}
+
+	return settings.level.Enabled(level)
+}
+
+// New creates the root logger described by conf, together with the levels that can be changed later.
+func New(conf config.LoggingConfig) (*zap.Logger, *Levels, error) {
+	levels := &Levels{}
+
+	if err := levels.Set(conf); err != nil {
+		return nil, nil, err
+	}
+
+	var encoder zapcore.Encoder
+
+	if conf.Format == config.LogFormatConsole {
+		encoderConfig := zap.NewDevelopmentEncoderConfig()
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	} else {
+		encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	}
+
+	// Levels are checked by levelCore, so the core itself accepts every level.
+	var core zapcore.Core = zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), zapcore.DebugLevel)
+
+	if conf.SamplingInitial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, conf.SamplingInitial, conf.SamplingThereafter)
+	}
+
+	return WithLevels(core, levels), levels, nil
+}
+
+// WithLevels creates a logger that writes to core what levels enable for the name of the logger.
+func WithLevels(core zapcore.Core, levels *Levels) *zap.Logger {
+	return zap.New(levelCore{Core: core, levels: levels}, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+}
+
+// levelCore drops the entries that the level of the logger they were written to does not enable.
+type levelCore struct {
+	zapcore.Core
+	levels *Levels
+}
+
+func (c levelCore) Enabled(level zapcore.Level) bool {
+	return c.levels.current.Load().minimum.Enabled(level)
+}
+
+func (c levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.Enabled(entry.LoggerName, entry.Level) {
+		return checked
+	}
+
+	return c.Core.Check(entry, checked)
+}
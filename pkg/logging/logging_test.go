@@ -0,0 +1,69 @@
+package logging
+
+import (
+	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	userproto "github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"kwekker-worker/pkg/config"
+	"testing"
+)
+
+func observedLogger(t *testing.T, conf config.LoggingConfig) (*Levels, *observer.ObservedLogs, func(name string, message string)) {
+	levels := &Levels{}
+
+	if err := levels.Set(conf); err != nil {
+		t.Fatalf("Levels should be set, but are not: %v", err)
+	}
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := WithLevels(core, levels)
+
+	return levels, logs, func(name string, message string) {
+		logger.Named(name).Debug(message)
+	}
+}
+
+func TestPackageLevelsOverrideLevel(t *testing.T) {
+	_, logs, debug := observedLogger(t, config.LoggingConfig{Level: "info", PackageLevels: []string{"rabbitmq=debug"}})
+
+	debug("worker", "dropped")
+	debug("rabbitmq", "kept")
+	debug("rabbitmq.consumer", "kept by parent")
+
+	entries := logs.AllUntimed()
+
+	if len(entries) != 2 || entries[0].Message != "kept" || entries[1].Message != "kept by parent" {
+		t.Errorf("Only debug messages of rabbitmq should be logged, but %v are", entries)
+	}
+}
+
+func TestSetChangesLevels(t *testing.T) {
+	levels, logs, debug := observedLogger(t, config.LoggingConfig{Level: "info"})
+
+	debug("worker", "dropped")
+
+	if err := levels.Set(config.LoggingConfig{Level: "debug"}); err != nil {
+		t.Fatalf("Levels should be set, but are not: %v", err)
+	}
+
+	debug("worker", "kept")
+
+	if entries := logs.AllUntimed(); len(entries) != 1 || entries[0].Message != "kept" {
+		t.Errorf("Only the message logged after lowering the level should be logged, but %v are", entries)
+	}
+
+	if err := levels.Set(config.LoggingConfig{Level: "debug", PackageLevels: []string{"db"}}); err == nil {
+		t.Errorf("Package levels without a level should be rejected, but are not")
+	}
+}
+
+func TestEntityId(t *testing.T) {
+	if id := EntityId(&kwekproto.DeleteKwek{KwekGuid: "kwek"}); id != "kwek" {
+		t.Errorf("Entity ID of a kwek message should be its GUID, but is %q", id)
+	}
+
+	if id := EntityId(&userproto.DeleteUser{UserId: "user"}); id != "user" {
+		t.Errorf("Entity ID of a user message should be the user ID, but is %q", id)
+	}
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"expvar"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+)
+
+var (
+	// MessagesDeadLettered counts the messages moved to a dead-letter queue, by reason.
+	MessagesDeadLettered = expvar.NewMap("messages_dead_lettered")
+	// KweksRateLimited counts the kweks that were rejected because their author exceeded the rate limit.
+	KweksRateLimited = expvar.NewInt("kweks_rate_limited")
+	// DryRunOutcomes counts the messages handled in dry-run mode, by queue and then by outcome.
+	DryRunOutcomes = expvar.NewMap("dry_run_outcomes")
+
+	dryRunOutcomesMutex sync.Mutex
+)
+
+// OutcomeAccepted is the dry-run outcome of messages that would have been applied. Other outcomes are
+// the reasons the message would have been dead-lettered for.
+const OutcomeAccepted = "accepted"
+
+// RecordDryRunOutcome counts a message from queue that would have had the given outcome.
+func RecordDryRunOutcome(queue string, outcome string) {
+	dryRunOutcomesMutex.Lock()
+	outcomes, ok := DryRunOutcomes.Get(queue).(*expvar.Map)
+
+	if !ok {
+		outcomes = new(expvar.Map).Init()
+		DryRunOutcomes.Set(queue, outcomes)
+	}
+
+	dryRunOutcomesMutex.Unlock()
+
+	outcomes.Add(outcome, 1)
+}
+
+// Serve exposes the metrics as JSON on /debug/vars. It blocks until the server fails.
+func Serve(logger *zap.SugaredLogger, address string) {
+	logger = logger.Named("metrics")
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	logger.Debug("Serving metrics", zap.String("address", address))
+
+	if err := http.ListenAndServe(address, mux); err != nil {
+		logger.Error("Failed to serve metrics", zap.Error(err))
+	}
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"expvar"
+	"sync"
+	"testing"
+)
+
+func TestRecordDryRunOutcomeCountsPerQueue(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			RecordDryRunOutcome("test-queue", OutcomeAccepted)
+		}()
+	}
+
+	wg.Wait()
+	RecordDryRunOutcome("test-queue", "validation failed")
+
+	outcomes, ok := DryRunOutcomes.Get("test-queue").(*expvar.Map)
+
+	if !ok {
+		t.Fatalf("Outcomes of test-queue should be recorded, but are not")
+	}
+
+	if accepted := outcomes.Get(OutcomeAccepted).String(); accepted != "10" {
+		t.Errorf("Accepted count should be 10, but is %s", accepted)
+	}
+
+	if failed := outcomes.Get("validation failed").String(); failed != "1" {
+		t.Errorf("Validation failed count should be 1, but is %s", failed)
+	}
+}
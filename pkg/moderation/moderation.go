@@ -0,0 +1,115 @@
+package moderation
+
+import (
+	"kwekker-worker/pkg/config"
+	"sync"
+)
+
+type Verdict int
+
+const (
+	Allow Verdict = iota
+	Flag
+	Reject
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Allow:
+		return "allow"
+	case Flag:
+		return "flag"
+	case Reject:
+		return "reject"
+	default:
+		return "unknown"
+	}
+}
+
+// Rule is a single moderation check. Besides the built-in rules, custom rules can be added with Moderator.AddRule.
+type Rule interface {
+	// Name identifies the rule in the recorded moderation results.
+	Name() string
+	// Check returns the verdict for text, with a human-readable reason when the verdict is not Allow.
+	Check(text string) (Verdict, string)
+}
+
+type Result struct {
+	Rule    string
+	Verdict Verdict
+	Reason  string
+}
+
+// Outcome is the combined result of all rules. Its verdict is the strictest verdict of any rule.
+type Outcome struct {
+	Verdict Verdict
+	Results []Result
+}
+
+type Moderator struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// NewModerator creates a moderator with the built-in rules that are enabled in the configuration.
+func NewModerator(config config.ModerationConfig) (*Moderator, error) {
+	moderator := &Moderator{}
+
+	if config.BannedWordsFile != "" {
+		rule, err := LoadBannedWords(config.BannedWordsFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		moderator.AddRule(rule)
+	}
+
+	if len(config.BlockedDomains) > 0 {
+		moderator.AddRule(NewBlockedDomainsRule(config.BlockedDomains))
+	}
+
+	if config.MaxCapsRatio > 0 {
+		moderator.AddRule(&CapsRule{MinLetters: config.MinCapsLetters, MaxRatio: config.MaxCapsRatio})
+	}
+
+	if config.MaxRepeatedCharacters > 0 {
+		moderator.AddRule(&RepeatedCharacterRule{MaxRepeats: config.MaxRepeatedCharacters})
+	}
+
+	return moderator, nil
+}
+
+func (m *Moderator) AddRule(rule Rule) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rules = append(m.rules, rule)
+}
+
+// Moderate runs every rule against text.
+func (m *Moderator) Moderate(text string) Outcome {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	outcome := Outcome{
+		Verdict: Allow,
+		Results: make([]Result, 0, len(m.rules)),
+	}
+
+	for _, rule := range m.rules {
+		verdict, reason := rule.Check(text)
+
+		outcome.Results = append(outcome.Results, Result{
+			Rule:    rule.Name(),
+			Verdict: verdict,
+			Reason:  reason,
+		})
+
+		if verdict > outcome.Verdict {
+			outcome.Verdict = verdict
+		}
+	}
+
+	return outcome
+}
@@ -0,0 +1,121 @@
+package moderation
+
+import (
+	"kwekker-worker/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type alwaysFlagRule struct{}
+
+func (alwaysFlagRule) Name() string {
+	return "always-flag"
+}
+
+func (alwaysFlagRule) Check(string) (Verdict, string) {
+	return Flag, "flagged"
+}
+
+func TestModerateWithoutRulesAllows(t *testing.T) {
+	outcome := (&Moderator{}).Moderate("Hello world!")
+
+	if outcome.Verdict != Allow {
+		t.Errorf("Verdict should be allow, but is %s", outcome.Verdict)
+	}
+}
+
+func TestModerateUsesStrictestVerdict(t *testing.T) {
+	moderator := &Moderator{}
+	moderator.AddRule(alwaysFlagRule{})
+	moderator.AddRule(NewBannedWordsRule([]string{"spam"}))
+
+	outcome := moderator.Moderate("Buy SPAM now")
+
+	if outcome.Verdict != Reject {
+		t.Errorf("Verdict should be reject, but is %s", outcome.Verdict)
+	}
+
+	if len(outcome.Results) != 2 {
+		t.Errorf("Outcome should have a result per rule, but has %d", len(outcome.Results))
+	}
+}
+
+func TestNewModeratorLoadsBannedWordsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+
+	if err := os.WriteFile(path, []byte("# comment\n\nfoo\nBar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	moderator, err := NewModerator(config.ModerationConfig{BannedWordsFile: path})
+
+	if err != nil {
+		t.Fatalf("Moderator should be created, but failed: %v", err)
+	}
+
+	if outcome := moderator.Moderate("foo bar"); outcome.Verdict != Reject {
+		t.Errorf("Verdict should be reject, but is %s", outcome.Verdict)
+	}
+
+	if outcome := moderator.Moderate("comment"); outcome.Verdict != Allow {
+		t.Errorf("Verdict should be allow, but is %s", outcome.Verdict)
+	}
+}
+
+func TestNewModeratorWithMissingBannedWordsFile(t *testing.T) {
+	_, err := NewModerator(config.ModerationConfig{BannedWordsFile: filepath.Join(t.TempDir(), "missing.txt")})
+
+	if err == nil {
+		t.Errorf("Moderator should not be created, but was")
+	}
+}
+
+func TestBannedWordsRuleMatchesWholeWordsOnly(t *testing.T) {
+	rule := NewBannedWordsRule([]string{"ass"})
+
+	if verdict, _ := rule.Check("A classic class"); verdict != Allow {
+		t.Errorf("Verdict should be allow, but is %s", verdict)
+	}
+}
+
+func TestBlockedDomainsRuleMatchesSubdomains(t *testing.T) {
+	rule := NewBlockedDomainsRule([]string{"Evil.com"})
+
+	if verdict, _ := rule.Check("see https://www.evil.com/path"); verdict != Reject {
+		t.Errorf("Verdict should be reject, but is %s", verdict)
+	}
+
+	if verdict, _ := rule.Check("see notevil.com"); verdict != Allow {
+		t.Errorf("Verdict should be allow, but is %s", verdict)
+	}
+}
+
+func TestCapsRule(t *testing.T) {
+	rule := &CapsRule{MinLetters: 10, MaxRatio: 0.7}
+
+	if verdict, _ := rule.Check("THIS IS VERY LOUD"); verdict != Flag {
+		t.Errorf("Verdict should be flag, but is %s", verdict)
+	}
+
+	if verdict, _ := rule.Check("OK"); verdict != Allow {
+		t.Errorf("Verdict should be allow for short texts, but is %s", verdict)
+	}
+
+	if verdict, _ := rule.Check("This Is Title Case"); verdict != Allow {
+		t.Errorf("Verdict should be allow, but is %s", verdict)
+	}
+}
+
+func TestRepeatedCharacterRule(t *testing.T) {
+	rule := &RepeatedCharacterRule{MaxRepeats: 5}
+
+	if verdict, _ := rule.Check("Nooooooooo"); verdict != Flag {
+		t.Errorf("Verdict should be flag, but is %s", verdict)
+	}
+
+	if verdict, _ := rule.Check("Noooo" + strings.Repeat(" ", 20) + "way"); verdict != Allow {
+		t.Errorf("Verdict should be allow, but is %s", verdict)
+	}
+}
@@ -0,0 +1,185 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// BannedWordsRule rejects texts that contain any of a list of words. Matching is case-insensitive
+// and on whole words only, so banning "ass" does not reject "class".
+type BannedWordsRule struct {
+	words map[string]bool
+}
+
+// LoadBannedWords reads a banned-word list with one word per line. Empty lines and lines starting with '#' are ignored.
+func LoadBannedWords(path string) (*BannedWordsRule, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open banned word list: %w", err)
+	}
+
+	defer file.Close()
+
+	words := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		words = append(words, line)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read banned word list: %w", err)
+	}
+
+	return NewBannedWordsRule(words), nil
+}
+
+func NewBannedWordsRule(words []string) *BannedWordsRule {
+	rule := &BannedWordsRule{
+		words: make(map[string]bool, len(words)),
+	}
+
+	for _, word := range words {
+		rule.words[strings.ToLower(word)] = true
+	}
+
+	return rule
+}
+
+func (r *BannedWordsRule) Name() string {
+	return "banned-words"
+}
+
+func (r *BannedWordsRule) Check(text string) (Verdict, string) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	for _, word := range words {
+		if r.words[word] {
+			return Reject, fmt.Sprintf("contains banned word %q", word)
+		}
+	}
+
+	return Allow, ""
+}
+
+var domainPattern = regexp.MustCompile(`(?i)(?:https?://)?((?:[\p{L}\p{N}-]+\.)+\p{L}{2,})`)
+
+// BlockedDomainsRule rejects texts that link to a blocked domain or any of its subdomains.
+type BlockedDomainsRule struct {
+	domains []string
+}
+
+func NewBlockedDomainsRule(domains []string) *BlockedDomainsRule {
+	rule := &BlockedDomainsRule{
+		domains: make([]string, 0, len(domains)),
+	}
+
+	for _, domain := range domains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+
+		if domain != "" {
+			rule.domains = append(rule.domains, domain)
+		}
+	}
+
+	return rule
+}
+
+func (r *BlockedDomainsRule) Name() string {
+	return "blocked-domains"
+}
+
+func (r *BlockedDomainsRule) Check(text string) (Verdict, string) {
+	for _, match := range domainPattern.FindAllStringSubmatch(text, -1) {
+		host := strings.ToLower(match[1])
+
+		for _, domain := range r.domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return Reject, fmt.Sprintf("links to blocked domain %q", domain)
+			}
+		}
+	}
+
+	return Allow, ""
+}
+
+// CapsRule flags texts in which the share of uppercase letters exceeds MaxRatio.
+// Texts with fewer than MinLetters letters are never flagged, so short shouts like "OK" pass.
+type CapsRule struct {
+	MinLetters int
+	MaxRatio   float64
+}
+
+func (r *CapsRule) Name() string {
+	return "excessive-caps"
+}
+
+func (r *CapsRule) Check(text string) (Verdict, string) {
+	letters, uppercase := 0, 0
+
+	for _, char := range text {
+		if !unicode.IsLetter(char) {
+			continue
+		}
+
+		letters++
+
+		if unicode.IsUpper(char) {
+			uppercase++
+		}
+	}
+
+	if letters < r.MinLetters || letters == 0 {
+		return Allow, ""
+	}
+
+	ratio := float64(uppercase) / float64(letters)
+
+	if ratio > r.MaxRatio {
+		return Flag, fmt.Sprintf("%.0f%% of letters are uppercase", ratio*100)
+	}
+
+	return Allow, ""
+}
+
+// RepeatedCharacterRule flags texts that repeat the same character more than MaxRepeats times in a row.
+type RepeatedCharacterRule struct {
+	MaxRepeats int
+}
+
+func (r *RepeatedCharacterRule) Name() string {
+	return "repeated-characters"
+}
+
+func (r *RepeatedCharacterRule) Check(text string) (Verdict, string) {
+	var previous rune
+	run := 0
+
+	for _, char := range text {
+		if char == previous {
+			run++
+		} else {
+			previous = char
+			run = 1
+		}
+
+		if run > r.MaxRepeats && !unicode.IsSpace(char) {
+			return Flag, fmt.Sprintf("repeats %q more than %d times", char, r.MaxRepeats)
+		}
+	}
+
+	return Allow, ""
+}
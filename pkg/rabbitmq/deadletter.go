@@ -0,0 +1,178 @@
+package rabbitmq
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+	"time"
+)
+
+// Headers deadLetter gives a message, describing why and where it was rejected.
+const (
+	reasonHeader           = "x-kwekker-reason"
+	queueHeader            = "x-kwekker-queue"
+	exchangeHeader         = "x-kwekker-exchange"
+	routingKeyHeader       = "x-kwekker-routing-key"
+	rejectedAtHeader       = "x-kwekker-rejected-at"
+	errorHeader            = "x-kwekker-error"
+	validationErrorsHeader = "x-kwekker-validation-errors"
+
+	// The first rejection of a message and how often it has been rejected, which survive replaying it.
+	firstReasonHeader     = "x-kwekker-first-reason"
+	firstRejectedAtHeader = "x-kwekker-first-rejected-at"
+	deathsHeader          = "x-kwekker-deaths"
+)
+
+// rejectionHeaders describe only the latest rejection of a message, and are removed when it is replayed.
+var rejectionHeaders = []string{
+	reasonHeader,
+	queueHeader,
+	exchangeHeader,
+	routingKeyHeader,
+	rejectedAtHeader,
+	errorHeader,
+	validationErrorsHeader,
+}
+
+// DeadLetter is a message in a dead-letter queue, with what its headers tell about its rejection.
+type DeadLetter struct {
+	Delivery amqp.Delivery
+	Reason   string
+	// Queue, Exchange and RoutingKey tell where the message was rejected and where it was published to.
+	Queue            string
+	Exchange         string
+	RoutingKey       string
+	RejectedAt       time.Time
+	Error            string
+	ValidationErrors string
+	// FirstReason and FirstRejectedAt describe the first time the message was rejected, before it was replayed.
+	FirstReason     string
+	FirstRejectedAt time.Time
+	// Deaths is the number of times the message has been rejected.
+	Deaths int
+}
+
+// ParseDeadLetter reads the headers of a message taken from a dead-letter queue. Messages dead-lettered by
+// the broker instead of the worker only have its x-death header, which is used as a fallback.
+func ParseDeadLetter(delivery amqp.Delivery) DeadLetter {
+	headers := delivery.Headers
+
+	letter := DeadLetter{
+		Delivery:         delivery,
+		Reason:           headerString(headers, reasonHeader),
+		Queue:            headerString(headers, queueHeader),
+		Exchange:         headerString(headers, exchangeHeader),
+		RoutingKey:       headerString(headers, routingKeyHeader),
+		RejectedAt:       headerTime(headers, rejectedAtHeader),
+		Error:            headerString(headers, errorHeader),
+		ValidationErrors: headerString(headers, validationErrorsHeader),
+		FirstReason:      headerString(headers, firstReasonHeader),
+		FirstRejectedAt:  headerTime(headers, firstRejectedAtHeader),
+		Deaths:           headerInt(headers, deathsHeader),
+	}
+
+	// The broker lists the deaths of a message in x-death, most recent first.
+	deaths, _ := headers["x-death"].([]any)
+
+	for i, death := range deaths {
+		table, ok := death.(amqp.Table)
+
+		if !ok {
+			continue
+		}
+
+		if i == 0 && letter.Reason == "" {
+			letter.Reason = headerString(table, "reason")
+			letter.Queue = headerString(table, "queue")
+			letter.Exchange = headerString(table, "exchange")
+			letter.RejectedAt = headerTime(table, "time")
+
+			if routingKeys, ok := table["routing-keys"].([]any); ok && len(routingKeys) > 0 {
+				letter.RoutingKey, _ = routingKeys[0].(string)
+			}
+		}
+
+		if i == len(deaths)-1 && letter.FirstReason == "" {
+			letter.FirstReason = headerString(table, "reason")
+			letter.FirstRejectedAt = headerTime(table, "time")
+		}
+
+		if headers[deathsHeader] == nil {
+			letter.Deaths += headerInt(table, "count")
+		}
+	}
+
+	if letter.FirstReason == "" {
+		letter.FirstReason = letter.Reason
+		letter.FirstRejectedAt = letter.RejectedAt
+	}
+
+	if letter.Deaths < 1 {
+		letter.Deaths = 1
+	}
+
+	return letter
+}
+
+// replayPublishing returns the publishing that replays letter with the given body. It keeps the headers that
+// identify and trace the message and its earlier rejections, but not those about its latest rejection.
+func replayPublishing(letter DeadLetter, body []byte) amqp.Publishing {
+	headers := amqp.Table{}
+
+	for key, value := range letter.Delivery.Headers {
+		headers[key] = value
+	}
+
+	for _, key := range rejectionHeaders {
+		delete(headers, key)
+	}
+
+	// The time it was first published would make the latency of a replayed message meaningless.
+	delete(headers, PublishedAtHeader)
+
+	headers[firstReasonHeader] = letter.FirstReason
+
+	// A message rejected without a timestamp would otherwise claim it was first rejected in the year 1.
+	if !letter.FirstRejectedAt.IsZero() {
+		headers[firstRejectedAtHeader] = letter.FirstRejectedAt
+	}
+
+	headers[deathsHeader] = int64(letter.Deaths)
+
+	return amqp.Publishing{
+		Headers:       headers,
+		ContentType:   letter.Delivery.ContentType,
+		DeliveryMode:  amqp.Persistent,
+		CorrelationId: letter.Delivery.CorrelationId,
+		MessageId:     letter.Delivery.MessageId,
+		Timestamp:     letter.Delivery.Timestamp,
+		Type:          letter.Delivery.Type,
+		Body:          body,
+	}
+}
+
+func headerString(headers amqp.Table, key string) string {
+	value, _ := headers[key].(string)
+	return value
+}
+
+func headerTime(headers amqp.Table, key string) time.Time {
+	value, _ := headers[key].(time.Time)
+	return value
+}
+
+// headerInt reads an integer header, which the broker may have encoded in any of the AMQP integer types.
+func headerInt(headers amqp.Table, key string) int {
+	switch value := headers[key].(type) {
+	case int8:
+		return int(value)
+	case int16:
+		return int(value)
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case int:
+		return value
+	default:
+		return 0
+	}
+}
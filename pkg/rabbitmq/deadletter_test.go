@@ -0,0 +1,122 @@
+package rabbitmq
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+	"testing"
+	"time"
+)
+
+func TestParseDeadLetterFromWorkerHeaders(t *testing.T) {
+	rejectedAt := time.Date(2022, 11, 2, 12, 0, 0, 0, time.UTC)
+	firstRejectedAt := rejectedAt.Add(-time.Hour)
+
+	letter := ParseDeadLetter(amqp.Delivery{Headers: amqp.Table{
+		"x-kwekker-reason":            "validation failed",
+		"x-kwekker-queue":             "kwek.create",
+		"x-kwekker-exchange":          "kwek",
+		"x-kwekker-routing-key":       "create",
+		"x-kwekker-rejected-at":       rejectedAt,
+		"x-kwekker-first-reason":      "conflict",
+		"x-kwekker-first-rejected-at": firstRejectedAt,
+		"x-kwekker-deaths":            int32(3),
+	}})
+
+	if letter.Reason != "validation failed" || letter.Exchange != "kwek" || letter.RoutingKey != "create" {
+		t.Errorf("Rejection should be read from the headers, but is %+v", letter)
+	}
+
+	if letter.FirstReason != "conflict" || !letter.FirstRejectedAt.Equal(firstRejectedAt) {
+		t.Errorf("First rejection should be a conflict at %s, but is %q at %s", firstRejectedAt, letter.FirstReason, letter.FirstRejectedAt)
+	}
+
+	if letter.Deaths != 3 {
+		t.Errorf("Deaths should be 3, but are %d", letter.Deaths)
+	}
+}
+
+func TestParseDeadLetterFromBrokerHeaders(t *testing.T) {
+	latest := time.Date(2022, 11, 2, 12, 0, 0, 0, time.UTC)
+	first := latest.Add(-time.Hour)
+
+	letter := ParseDeadLetter(amqp.Delivery{Headers: amqp.Table{
+		"x-death": []any{
+			amqp.Table{"reason": "expired", "queue": "kwek.create", "exchange": "kwek", "routing-keys": []any{"create"}, "time": latest, "count": int64(2)},
+			amqp.Table{"reason": "rejected", "queue": "kwek.create", "exchange": "kwek", "routing-keys": []any{"create"}, "time": first, "count": int64(1)},
+		},
+	}})
+
+	if letter.Reason != "expired" || letter.RoutingKey != "create" || !letter.RejectedAt.Equal(latest) {
+		t.Errorf("Rejection should be the most recent death, but is %+v", letter)
+	}
+
+	if letter.FirstReason != "rejected" || !letter.FirstRejectedAt.Equal(first) {
+		t.Errorf("First rejection should be the oldest death, but is %q at %s", letter.FirstReason, letter.FirstRejectedAt)
+	}
+
+	if letter.Deaths != 3 {
+		t.Errorf("Deaths should add up the counts of every death to 3, but are %d", letter.Deaths)
+	}
+
+	if ParseDeadLetter(amqp.Delivery{}).Deaths != 1 {
+		t.Errorf("A message without headers should have died once")
+	}
+}
+
+func TestReplayPublishingKeepsFirstRejectionOnly(t *testing.T) {
+	rejectedAt := time.Date(2022, 11, 2, 12, 0, 0, 0, time.UTC)
+
+	letter := ParseDeadLetter(amqp.Delivery{
+		MessageId:     "1",
+		CorrelationId: "request",
+		Body:          []byte("original"),
+		Headers: amqp.Table{
+			"traceparent":                 "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			PublishedAtHeader:             rejectedAt.Add(-time.Minute).UnixMicro(),
+			"x-kwekker-reason":            "processing failed",
+			"x-kwekker-queue":             "kwek.create",
+			"x-kwekker-exchange":          "kwek",
+			"x-kwekker-routing-key":       "create",
+			"x-kwekker-rejected-at":       rejectedAt,
+			"x-kwekker-error":             "connection reset",
+			"x-kwekker-validation-errors": "[]",
+			"x-kwekker-first-reason":      "conflict",
+			"x-kwekker-first-rejected-at": rejectedAt.Add(-time.Hour),
+			"x-kwekker-deaths":            int64(2),
+		},
+	})
+
+	publishing := replayPublishing(letter, []byte("edited"))
+
+	for _, header := range append(rejectionHeaders, PublishedAtHeader) {
+		if _, ok := publishing.Headers[header]; ok {
+			t.Errorf("Header %s should be removed, but is kept", header)
+		}
+	}
+
+	if publishing.Headers["x-kwekker-first-reason"] != "conflict" || publishing.Headers["x-kwekker-deaths"] != int64(2) {
+		t.Errorf("First reason and deaths should be kept, but headers are %v", publishing.Headers)
+	}
+
+	if publishing.Headers["traceparent"] == nil || publishing.MessageId != "1" || publishing.CorrelationId != "request" {
+		t.Errorf("Message should keep its identity and trace, but is %+v", publishing)
+	}
+
+	if string(publishing.Body) != "edited" {
+		t.Errorf("Body should be the edited one, but is %q", publishing.Body)
+	}
+
+	// The replayed message still tells how often and why it was first rejected.
+	replayed := ParseDeadLetter(amqp.Delivery{Headers: publishing.Headers})
+
+	if replayed.FirstReason != "conflict" || replayed.Deaths != 2 {
+		t.Errorf("Replayed message should have died twice, first of a conflict, but is %+v", replayed)
+	}
+}
+
+func TestReplayPublishingLeavesOutUnknownFirstRejection(t *testing.T) {
+	publishing := replayPublishing(ParseDeadLetter(amqp.Delivery{}), nil)
+
+	if _, ok := publishing.Headers["x-kwekker-first-rejected-at"]; ok {
+		t.Errorf("Time of the first rejection should be left out when it is unknown, but is %v", publishing.Headers)
+	}
+}
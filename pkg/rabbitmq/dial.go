@@ -0,0 +1,66 @@
+package rabbitmq
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"kwekker-worker/pkg/config"
+	"net/url"
+	"os"
+)
+
+// Dial connects to the broker described by config. amqps:// URLs are dialled over TLS, verifying the
+// broker with the configured certificate authorities and presenting the client certificate, if any.
+func Dial(config config.RabbitMQConfig) (*amqp.Connection, error) {
+	connectionURL := config.ConnectionURL()
+	parsed, err := url.Parse(connectionURL)
+
+	if err != nil {
+		// The error would include the URL, and with it the password.
+		return nil, errors.New("invalid RabbitMQ URL")
+	}
+
+	if parsed.Scheme != "amqps" {
+		return amqp.Dial(connectionURL)
+	}
+
+	tlsConfig, err := newTLSConfig(config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return amqp.DialTLS(connectionURL, tlsConfig)
+}
+
+func newTLSConfig(config config.RabbitMQConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RabbitMQ CA file: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in RabbitMQ CA file %s", config.CAFile)
+		}
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to load RabbitMQ client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,63 @@
+package rabbitmq
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// dryRunPass keeps track of the messages of a queue that a dry run in requeue mode has seen. RabbitMQ puts a
+// message that is put back near the head of the queue rather than at its end, so messages come around again
+// long before the pass has gone through the queue. The pass therefore ends once it has seen as many distinct
+// messages as the queue held when it started, and holds on to the messages that come around again until then,
+// as putting them back right away would only have them delivered again.
+type dryRunPass struct {
+	depth int
+	seen  map[string]bool
+	held  []amqp.Delivery
+}
+
+// newDryRunPass starts a pass through a queue that holds depth messages.
+func newDryRunPass(depth int) *dryRunPass {
+	return &dryRunPass{depth: depth, seen: make(map[string]bool)}
+}
+
+// admit records msg, and reports whether it is the first time it was seen and should be handled. Messages
+// that were seen before are held until release.
+func (p *dryRunPass) admit(msg amqp.Delivery) bool {
+	key := deliveryKey(msg)
+
+	if p.seen[key] {
+		p.held = append(p.held, msg)
+		return false
+	}
+
+	p.seen[key] = true
+
+	return true
+}
+
+// complete reports whether the pass has seen as many distinct messages as the queue held when it started.
+func (p *dryRunPass) complete() bool {
+	return len(p.seen) >= p.depth
+}
+
+// release returns the messages that were held, and forgets them.
+func (p *dryRunPass) release() []amqp.Delivery {
+	held := p.held
+	p.held = nil
+
+	return held
+}
+
+// deliveryKey identifies a message by its ID, or by its body when it has none, so that messages without an
+// ID and with the same body count as one.
+func deliveryKey(msg amqp.Delivery) string {
+	if msg.MessageId != "" {
+		return "id:" + msg.MessageId
+	}
+
+	sum := sha256.Sum256(msg.Body)
+
+	return "body:" + hex.EncodeToString(sum[:])
+}
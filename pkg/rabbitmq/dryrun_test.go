@@ -0,0 +1,64 @@
+package rabbitmq
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+	"testing"
+)
+
+func TestDryRunPassSeesEveryMessageOnce(t *testing.T) {
+	pass := newDryRunPass(4)
+	deliveries := []amqp.Delivery{
+		{MessageId: "1", Body: []byte("a")},
+		{MessageId: "2", Body: []byte("a"), Redelivered: true},
+		{Body: []byte("b")},
+		{Body: []byte("c")},
+	}
+
+	for _, delivery := range deliveries {
+		if !pass.admit(delivery) {
+			t.Errorf("Message %q with body %q should be seen for the first time, but is not", delivery.MessageId, delivery.Body)
+		}
+	}
+
+	for _, delivery := range deliveries {
+		delivery.Redelivered = true
+
+		if pass.admit(delivery) {
+			t.Errorf("Message %q with body %q should have been seen before, but has not", delivery.MessageId, delivery.Body)
+		}
+	}
+}
+
+func TestDryRunPassGoesPastMessagesThatComeBackEarly(t *testing.T) {
+	pass := newDryRunPass(5)
+
+	// With a prefetch of two, RabbitMQ delivers the messages it got back before the ones behind them.
+	sequence := []string{"1", "2", "1", "3", "2", "1", "4", "3", "5"}
+	handled := make([]string, 0)
+
+	for i, id := range sequence {
+		if pass.complete() {
+			t.Fatalf("Pass should not be complete before message %d, but is", i)
+		}
+
+		if pass.admit(amqp.Delivery{MessageId: id}) {
+			handled = append(handled, id)
+		}
+	}
+
+	if len(handled) != 5 {
+		t.Errorf("Every message of the queue should be handled once, but %v are", handled)
+	}
+
+	if !pass.complete() {
+		t.Errorf("Pass should be complete after seeing every message, but is not")
+	}
+
+	if held := pass.release(); len(held) != 4 {
+		t.Errorf("Messages that came back should be held until the pass ends, but %d are", len(held))
+	}
+
+	if held := pass.release(); len(held) != 0 {
+		t.Errorf("Released messages should be forgotten, but %d are held", len(held))
+	}
+}
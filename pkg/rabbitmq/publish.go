@@ -0,0 +1,119 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/proto"
+	"kwekker-worker/pkg/config"
+	"time"
+)
+
+func newPublishing(protobuf proto.Message) (amqp.Publishing, error) {
+	body, err := proto.Marshal(protobuf)
+
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	return amqp.Publishing{
+		Headers:      amqp.Table{PublishedAtHeader: time.Now().UnixMicro()},
+		DeliveryMode: amqp.Persistent,
+		ContentType:  "application/protobuf",
+		MessageId:    uuid.NewString(),
+		Timestamp:    time.Now(),
+		Body:         body,
+	}, nil
+}
+
+var (
+	errNotConfirmed = errors.New("the broker did not confirm the message")
+	errUnroutable   = errors.New("no queue is bound to the exchange with the routing key of the message")
+)
+
+// Publisher sends messages to the exchange of a queue with the routing key it is bound with, the way the
+// services that produce messages for the worker do. It waits until the broker confirms each message, so
+// that a published message is known to have been stored in a queue.
+type Publisher struct {
+	mqchannel *amqp.Channel
+	returns   chan amqp.Return
+}
+
+// NewPublisher puts mqchannel in confirm mode, after which it should only be used by the publisher.
+func NewPublisher(mqchannel *amqp.Channel) (*Publisher, error) {
+	if err := mqchannel.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	return &Publisher{
+		mqchannel: mqchannel,
+		returns:   mqchannel.NotifyReturn(make(chan amqp.Return, 1)),
+	}, nil
+}
+
+// Publish publishes protobuf and waits for the broker to confirm it. It returns the message ID the message
+// was given, and an error if the broker rejected the message or could not route it to any queue.
+func (p *Publisher) Publish(ctx context.Context, queueData config.QueueData, protobuf proto.Message) (string, error) {
+	publishing, err := newPublishing(protobuf)
+
+	if err != nil {
+		return "", err
+	}
+
+	return publishing.MessageId, p.publish(ctx, queueData.Exchange, queueData.RoutingKey, publishing)
+}
+
+// Replay publishes a dead-lettered message with the given body to the exchange it was originally published
+// to, and waits for the broker to confirm it. The message keeps its ID, and counts its earlier rejections.
+func (p *Publisher) Replay(ctx context.Context, letter DeadLetter, body []byte) error {
+	if letter.Exchange == "" {
+		return errors.New("the message does not tell which exchange it was published to")
+	}
+
+	return p.publish(ctx, letter.Exchange, letter.RoutingKey, replayPublishing(letter, body))
+}
+
+func (p *Publisher) publish(ctx context.Context, exchange string, routingKey string, publishing amqp.Publishing) error {
+	// Mandatory messages that cannot be routed are returned, and then confirmed nonetheless.
+	confirmation, err := p.mqchannel.PublishWithDeferredConfirmWithContext(
+		ctx,
+		exchange,
+		routingKey,
+		true,
+		false,
+		publishing,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if !confirmation.Wait() {
+		return errNotConfirmed
+	}
+
+	// The broker returns a message before confirming it, so a return has been received by now if there is one.
+	for {
+		select {
+		case returned := <-p.returns:
+			if isReturned(returned, publishing) {
+				return errUnroutable
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// isReturned tells whether returned is publishing, coming back because it could not be routed. A replayed
+// message may have no ID, in which case it has to be told apart from other messages without one by its body.
+func isReturned(returned amqp.Return, publishing amqp.Publishing) bool {
+	if returned.MessageId != publishing.MessageId {
+		return false
+	}
+
+	return publishing.MessageId != "" || bytes.Equal(returned.Body, publishing.Body)
+}
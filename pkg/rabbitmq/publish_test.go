@@ -0,0 +1,65 @@
+package rabbitmq
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+func TestPublishedMessageCarriesItsPublishTime(t *testing.T) {
+	before := time.Now().Truncate(time.Microsecond)
+	publishing, err := newPublishing(timestamppb.Now())
+
+	if err != nil {
+		t.Fatalf("Publishing should be created, but is not: %v", err)
+	}
+
+	stamped := publishedAt(amqp.Delivery{Headers: publishing.Headers})
+
+	if stamped.Before(before) || stamped.After(time.Now()) {
+		t.Errorf("Publish time should be the time of publishing, but is %s", stamped)
+	}
+
+	if !publishedAt(amqp.Delivery{}).IsZero() {
+		t.Errorf("Publish time of a message without header should be zero, but is not")
+	}
+}
+
+func TestIsReturnedTellsMessagesWithoutIdApartByBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		returned  amqp.Return
+		published amqp.Publishing
+		matches   bool
+	}{
+		{
+			name:      "same ID",
+			returned:  amqp.Return{MessageId: "1", Body: []byte("a")},
+			published: amqp.Publishing{MessageId: "1", Body: []byte("b")},
+			matches:   true,
+		},
+		{
+			name:      "other ID",
+			returned:  amqp.Return{MessageId: "2", Body: []byte("a")},
+			published: amqp.Publishing{MessageId: "1", Body: []byte("a")},
+		},
+		{
+			name:      "no ID and same body",
+			returned:  amqp.Return{Body: []byte("a")},
+			published: amqp.Publishing{Body: []byte("a")},
+			matches:   true,
+		},
+		{
+			name:      "no ID and other body",
+			returned:  amqp.Return{Body: []byte("a")},
+			published: amqp.Publishing{Body: []byte("b")},
+		},
+	}
+
+	for _, test := range tests {
+		if matches := isReturned(test.returned, test.published); matches != test.matches {
+			t.Errorf("Return with %s should match the publishing: %t, but matches: %t", test.name, test.matches, matches)
+		}
+	}
+}
@@ -1,28 +1,117 @@
 package rabbitmq
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"go.uber.org/zap"
 	"google.golang.org/protobuf/proto"
 	"kwekker-worker/pkg/config"
+	"kwekker-worker/pkg/logging"
+	"kwekker-worker/pkg/metrics"
 	"kwekker-worker/pkg/validation"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
+// DeadLetterExchange routes rejected messages to the dead-letter queue of the queue they were rejected from.
+const DeadLetterExchange = "kwekker-dead-letter"
+
+// Reasons for dead-lettering a message, stored in its x-kwekker-reason header.
+const (
+	ReasonInvalidProtobuf  = "invalid protobuf"
+	ReasonValidationFailed = "validation failed"
+	ReasonRateLimited      = "rate limited"
+	ReasonConflict         = "conflict"
+	ReasonProcessingFailed = "processing failed"
+)
+
+// PublishedAtHeader holds the time a message was published, in microseconds since the Unix epoch. The
+// AMQP timestamp property only has a precision of seconds, which is too coarse for measuring latency.
+const PublishedAtHeader = "x-kwekker-published-at"
+
+// Message is a validated protobuf together with the delivery details it arrived with.
+// It must be settled with either Ack or Reject once it has been handled.
+type Message struct {
+	Protobuf   proto.Message
+	Queue      string
+	RoutingKey string
+	MessageId  string
+	// TraceId identifies the request that caused the message, see traceId.
+	TraceId string
+	// PublishedAt is the time the message was published according to its PublishedAtHeader, or zero if it has none.
+	PublishedAt time.Time
+
+	delivery  amqp.Delivery
+	mqchannel *amqp.Channel
+	requeue   bool
+}
+
+func (m Message) Ack() error {
+	return m.delivery.Ack(false)
+}
+
+// Reject moves the message to the dead-letter queue of the queue it was consumed from.
+func (m Message) Reject(reason string, cause error) error {
+	return deadLetter(m.mqchannel, m.Queue, m.delivery, reason, cause)
+}
+
+// Release settles a message handled in dry-run mode without affecting the queue it came from. In requeue
+// mode the message is put back, and otherwise its copy is dropped from the shadow queue.
+func (m Message) Release() error {
+	return release(m.delivery, m.requeue)
+}
+
+func release(msg amqp.Delivery, requeue bool) error {
+	if requeue {
+		return msg.Nack(false, true)
+	}
+
+	return msg.Ack(false)
+}
+
+// DryRunQueue returns the name of the shadow queue that receives copies of the messages of queue in dry-run mode.
+func DryRunQueue(queue string) string {
+	return queue + ".dry-run"
+}
+
+// DeadLetterQueue returns the name of the queue that rejected messages of queue are moved to.
+func DeadLetterQueue(queue string) string {
+	return queue + ".dead-letter"
+}
+
+var errNotListening = errors.New("not listening to queues yet")
+
 type RabbitMQWorker struct {
-	logger *zap.SugaredLogger
-	config config.RabbitMQConfig
+	logger     *zap.SugaredLogger
+	config     config.RabbitMQConfig
+	dryRunMode string
+
+	// mu guards the channel and message channel that ListenToQueues consumes with, for Pause and Resume.
+	mu        sync.Mutex
+	mqchannel *amqp.Channel
+	msgchan   chan<- Message
 }
 
 func NewRabbitMQWorker(logger *zap.SugaredLogger, config config.RabbitMQConfig) *RabbitMQWorker {
 	return &RabbitMQWorker{
-		logger: logger,
+		logger: logger.Named("rabbitmq"),
 		config: config,
 	}
 }
 
-func (w *RabbitMQWorker) ListenToQueues(queues config.Queues, protobufchan chan<- proto.Message) {
+// EnableDryRun makes the worker consume in the given dry-run mode. Rejected messages are then counted in
+// metrics.DryRunOutcomes instead of being dead-lettered, and messages must be settled with Release.
+// It must be called before ListenToQueues.
+func (w *RabbitMQWorker) EnableDryRun(mode string) {
+	w.dryRunMode = mode
+}
+
+func (w *RabbitMQWorker) ListenToQueues(queues config.Queues, msgchan chan<- Message) {
 	conn, err := w.connect()
 
 	if err != nil {
@@ -41,30 +130,71 @@ func (w *RabbitMQWorker) ListenToQueues(queues config.Queues, protobufchan chan<
 
 	defer mqchannel.Close()
 
-	exchanges := extractExchanges(queues)
+	if err = w.declare(queues, mqchannel); err != nil {
+		w.logger.Fatal("Failed to declare topology", zap.Error(err))
+	}
 
-	w.declareExchanges(exchanges, mqchannel)
-	w.declareAndBindQueues(queues, mqchannel)
-	w.consumeQueues(queues, mqchannel, protobufchan)
+	w.mu.Lock()
+	w.mqchannel = mqchannel
+	w.msgchan = msgchan
+	w.consumeQueues(queues, mqchannel, msgchan)
+	w.mu.Unlock()
 
 	select {}
 }
 
+// Pause stops consuming queue. Messages that were already delivered are still handled.
+func (w *RabbitMQWorker) Pause(queue string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.mqchannel == nil {
+		return errNotListening
+	}
+
+	return w.mqchannel.Cancel(queue, false)
+}
+
+// Resume starts consuming queue again after Pause. The queue is declared first, as it may have been
+// disabled when the worker started.
+func (w *RabbitMQWorker) Resume(queue string, queueData config.QueueData) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.mqchannel == nil {
+		return errNotListening
+	}
+
+	queues := config.Queues{queue: queueData}
+
+	if err := w.declare(queues, w.mqchannel); err != nil {
+		return err
+	}
+
+	w.consumeQueues(queues, w.mqchannel, w.msgchan)
+
+	return nil
+}
+
+// declare declares the topology of queues, and the shadow queues a dry run in shadow mode consumes.
+func (w *RabbitMQWorker) declare(queues config.Queues, mqchannel *amqp.Channel) error {
+	if err := DeclareTopology(mqchannel, queues); err != nil {
+		return err
+	}
+
+	if w.dryRunMode == config.DryRunShadow {
+		return declareShadowQueues(queues, mqchannel)
+	}
+
+	return nil
+}
+
 func (w *RabbitMQWorker) connect() (*amqp.Connection, error) {
 	var conn *amqp.Connection
 
 	for i := 0; i < 5; i++ {
 		var err error
-		conn, err = amqp.Dial(
-			fmt.Sprintf(
-				"amqp://%s:%s@%s:%d%s",
-				w.config.Username,
-				w.config.Password,
-				w.config.Host,
-				w.config.Port,
-				w.config.Vhost,
-			),
-		)
+		conn, err = Dial(w.config)
 
 		if err == nil {
 			break
@@ -94,10 +224,26 @@ func extractExchanges(queues config.Queues) []string {
 		exchanges = append(exchanges, exchange)
 	}
 
+	sort.Strings(exchanges)
+
 	return exchanges
 }
 
-func (w *RabbitMQWorker) declareExchanges(exchanges []string, mqchannel *amqp.Channel) {
+// DeclareTopology declares the exchanges of queues, the queues themselves and their dead-letter queues,
+// and binds them together. Declaring is idempotent, so this is safe to call when they exist already.
+func DeclareTopology(mqchannel *amqp.Channel, queues config.Queues) error {
+	if err := declareExchanges(extractExchanges(queues), mqchannel); err != nil {
+		return err
+	}
+
+	if err := declareAndBindQueues(queues, mqchannel); err != nil {
+		return err
+	}
+
+	return declareDeadLetterQueues(queues, mqchannel)
+}
+
+func declareExchanges(exchanges []string, mqchannel *amqp.Channel) error {
 	for _, exchange := range exchanges {
 		err := mqchannel.ExchangeDeclare(
 			exchange,
@@ -110,12 +256,14 @@ func (w *RabbitMQWorker) declareExchanges(exchanges []string, mqchannel *amqp.Ch
 		)
 
 		if err != nil {
-			w.logger.Fatal("Failed to declare exchange", err)
+			return fmt.Errorf("failed to declare exchange %s: %w", exchange, err)
 		}
 	}
+
+	return nil
 }
 
-func (w *RabbitMQWorker) declareAndBindQueues(queues config.Queues, mqchannel *amqp.Channel) {
+func declareAndBindQueues(queues config.Queues, mqchannel *amqp.Channel) error {
 	for queue, queueData := range queues {
 		_, err := mqchannel.QueueDeclare(
 			queue,
@@ -127,28 +275,141 @@ func (w *RabbitMQWorker) declareAndBindQueues(queues config.Queues, mqchannel *a
 		)
 
 		if err != nil {
-			w.logger.Fatal("Failed to declare queue", zap.Error(err))
+			return fmt.Errorf("failed to declare queue %s: %w", queue, err)
 		}
 
 		err = mqchannel.QueueBind(
 			queue,
+			queueData.RoutingKey,
+			queueData.Exchange,
+			false,
+			nil,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to bind queue %s: %w", queue, err)
+		}
+	}
+
+	return nil
+}
+
+func declareDeadLetterQueues(queues config.Queues, mqchannel *amqp.Channel) error {
+	err := mqchannel.ExchangeDeclare(
+		DeadLetterExchange,
+		"direct",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+
+	for queue := range queues {
+		_, err = mqchannel.QueueDeclare(
+			DeadLetterQueue(queue),
+			true,
+			false,
+			false,
+			false,
+			nil,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to declare dead-letter queue of %s: %w", queue, err)
+		}
+
+		err = mqchannel.QueueBind(
+			DeadLetterQueue(queue),
 			queue,
+			DeadLetterExchange,
+			false,
+			nil,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to bind dead-letter queue of %s: %w", queue, err)
+		}
+	}
+
+	return nil
+}
+
+// declareShadowQueues binds a temporary copy of every queue to its exchange, so that a dry run sees the same
+// messages as the workers consuming the queue without taking them away. The copies are deleted once the
+// dry run stops consuming them.
+func declareShadowQueues(queues config.Queues, mqchannel *amqp.Channel) error {
+	for queue, queueData := range queues {
+		_, err := mqchannel.QueueDeclare(
+			DryRunQueue(queue),
+			false,
+			true,
+			false,
+			false,
+			nil,
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to declare dry-run queue of %s: %w", queue, err)
+		}
+
+		err = mqchannel.QueueBind(
+			DryRunQueue(queue),
+			queueData.RoutingKey,
 			queueData.Exchange,
 			false,
 			nil,
 		)
 
 		if err != nil {
-			w.logger.Fatal("Failed to bind queue", zap.Error(err))
+			return fmt.Errorf("failed to bind dry-run queue of %s: %w", queue, err)
 		}
 	}
+
+	return nil
 }
 
-func (w *RabbitMQWorker) consumeQueues(queues config.Queues, mqchannel *amqp.Channel, protobufchan chan<- proto.Message) {
+func (w *RabbitMQWorker) consumeQueues(queues config.Queues, mqchannel *amqp.Channel, msgchan chan<- Message) {
 	for queue, queueData := range queues {
+		consumedQueue := queue
+		prefetch := queueData.Prefetch
+
+		var pass *dryRunPass
+
+		switch w.dryRunMode {
+		case config.DryRunShadow:
+			consumedQueue = DryRunQueue(queue)
+		case config.DryRunRequeue:
+			state, err := mqchannel.QueueDeclarePassive(queue, true, false, false, false, nil)
+
+			if err != nil {
+				w.logger.Fatal("Failed to inspect queue", zap.String("queue", queue), zap.Error(err))
+			}
+
+			if state.Messages == 0 {
+				w.logger.Info("Dry run has no messages to go through in the queue", zap.String("queue", queue))
+				continue
+			}
+
+			// Messages that come around again are held until the pass ends, so a prefetch limit could stop
+			// the pass before it reached the messages behind them.
+			pass = newDryRunPass(state.Messages)
+			prefetch = 0
+		}
+
+		// With global set to false, the limit applies to each consumer started on the channel from now on.
+		if err := mqchannel.Qos(prefetch, 0, false); err != nil {
+			w.logger.Fatal("Failed to set prefetch count", zap.Error(err))
+		}
+
+		// The queue name doubles as the consumer tag, which lets Pause cancel the consumer.
 		msgs, err := mqchannel.Consume(
+			consumedQueue,
 			queue,
-			"",
 			false,
 			false,
 			false,
@@ -160,35 +421,187 @@ func (w *RabbitMQWorker) consumeQueues(queues config.Queues, mqchannel *amqp.Cha
 			w.logger.Fatal("Failed to consume queue", zap.Error(err))
 		}
 
-		go w.handleMessages(msgs, queueData.Type, protobufchan)
+		go w.handleMessages(queue, msgs, mqchannel, queueData.Type, msgchan, pass)
 	}
 }
 
-func (w *RabbitMQWorker) handleMessages(msgs <-chan amqp.Delivery, prototype proto.Message, protobufchan chan<- proto.Message) {
+// handleMessages turns the deliveries of queue into messages for the worker. In requeue mode pass keeps track
+// of the messages the dry run has seen, and is nil otherwise.
+func (w *RabbitMQWorker) handleMessages(
+	queue string,
+	msgs <-chan amqp.Delivery,
+	mqchannel *amqp.Channel,
+	prototype proto.Message,
+	msgchan chan<- Message,
+	pass *dryRunPass,
+) {
+	requeue := pass != nil
+	passed := false
+
 	for msg := range msgs {
+		if requeue {
+			// Once the pass is over, the deliveries that were still on their way are put back as they arrive.
+			if passed {
+				w.release(msg, true)
+				continue
+			}
+
+			if !pass.admit(msg) {
+				continue
+			}
+
+			if pass.complete() {
+				passed = true
+				w.finishDryRunPass(queue, mqchannel, pass)
+			}
+		}
+
 		protobuf := proto.Clone(prototype)
 		err := proto.Unmarshal(msg.Body, protobuf)
+		logger := w.logger.With(logging.MessageFields(queue, msg.MessageId, logging.EntityId(protobuf), traceId(msg))...)
 
 		if err != nil {
-			w.logger.Error("Failed to unmarshal protobuf", zap.Error(err))
-			_ = msg.Nack(false, false)
+			logger.Error("Failed to unmarshal protobuf", zap.Error(err))
+			w.reject(mqchannel, queue, msg, ReasonInvalidProtobuf, err)
 			continue
 		}
 
+		validation.Normalize(protobuf)
 		valid := validation.Validate(protobuf)
 
 		if !valid.Valid {
-			w.logger.Error("Failed to validate protobuf", zap.Strings("errors", valid.Errors))
-			_ = msg.Nack(false, false)
+			logger.Error("Failed to validate protobuf", zap.Strings("errors", valid.Errors.Messages()))
+			w.reject(mqchannel, queue, msg, ReasonValidationFailed, valid.Errors)
 			continue
 		}
 
-		protobufchan <- protobuf
+		msgchan <- Message{
+			Protobuf:    protobuf,
+			Queue:       queue,
+			RoutingKey:  msg.RoutingKey,
+			MessageId:   msg.MessageId,
+			TraceId:     traceId(msg),
+			PublishedAt: publishedAt(msg),
+			delivery:    msg,
+			mqchannel:   mqchannel,
+			requeue:     requeue,
+		}
+	}
+}
 
-		err = msg.Ack(true)
+// finishDryRunPass stops consuming queue once a dry run in requeue mode has seen all of its messages, and puts
+// back the messages the pass held.
+func (w *RabbitMQWorker) finishDryRunPass(queue string, mqchannel *amqp.Channel, pass *dryRunPass) {
+	for _, msg := range pass.release() {
+		w.release(msg, true)
+	}
 
-		if err != nil {
-			w.logger.Error("Failed to acknowledge message", zap.Error(err))
+	if err := mqchannel.Cancel(queue, false); err != nil {
+		w.logger.Error("Failed to stop consuming queue after the dry run went through it", zap.String("queue", queue), zap.Error(err))
+		return
+	}
+
+	w.logger.Info("Dry run went through every message of the queue; no longer consuming it", zap.String("queue", queue))
+}
+
+// traceId returns the trace ID of the W3C traceparent header of msg, or else its correlation ID.
+func traceId(msg amqp.Delivery) string {
+	if traceparent, ok := msg.Headers["traceparent"].(string); ok {
+		// A traceparent header is version-traceid-parentid-flags, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+		if parts := strings.Split(traceparent, "-"); len(parts) == 4 && len(parts[1]) == 32 {
+			return parts[1]
 		}
 	}
+
+	return msg.CorrelationId
+}
+
+func publishedAt(msg amqp.Delivery) time.Time {
+	if microseconds, ok := msg.Headers[PublishedAtHeader].(int64); ok {
+		return time.UnixMicro(microseconds)
+	}
+
+	return time.Time{}
+}
+
+// reject dead-letters a message that failed before reaching the worker, or in dry-run mode counts it and releases it.
+func (w *RabbitMQWorker) reject(mqchannel *amqp.Channel, queue string, msg amqp.Delivery, reason string, cause error) {
+	if w.dryRunMode != "" {
+		metrics.RecordDryRunOutcome(queue, reason)
+		w.release(msg, w.dryRunMode == config.DryRunRequeue)
+		return
+	}
+
+	if err := deadLetter(mqchannel, queue, msg, reason, cause); err != nil {
+		w.logger.Error("Failed to dead-letter message", zap.Error(err))
+	}
+}
+
+func (w *RabbitMQWorker) release(msg amqp.Delivery, requeue bool) {
+	if err := release(msg, requeue); err != nil {
+		w.logger.Error("Failed to release dry-run message", zap.Error(err))
+	}
+}
+
+// deadLetter republishes a delivery to the dead-letter exchange with headers describing why and where it was
+// rejected, and then acknowledges the original. If republishing fails, the delivery is rejected instead.
+func deadLetter(mqchannel *amqp.Channel, queue string, msg amqp.Delivery, reason string, cause error) error {
+	headers := amqp.Table{}
+
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+
+	rejectedAt := time.Now().UTC()
+
+	headers[reasonHeader] = reason
+	headers[queueHeader] = queue
+	headers[exchangeHeader] = msg.Exchange
+	headers[routingKeyHeader] = msg.RoutingKey
+	headers[rejectedAtHeader] = rejectedAt
+	headers[deathsHeader] = int64(headerInt(msg.Headers, deathsHeader) + 1)
+
+	if _, replayed := headers[firstReasonHeader]; !replayed {
+		headers[firstReasonHeader] = reason
+		headers[firstRejectedAtHeader] = rejectedAt
+	}
+
+	if cause != nil {
+		headers[errorHeader] = cause.Error()
+	}
+
+	var validationErrors validation.Errors
+
+	if errors.As(cause, &validationErrors) {
+		if encoded, err := json.Marshal(validationErrors); err == nil {
+			headers[validationErrorsHeader] = string(encoded)
+		}
+	}
+
+	err := mqchannel.PublishWithContext(
+		context.Background(),
+		DeadLetterExchange,
+		queue,
+		false,
+		false,
+		amqp.Publishing{
+			Headers:       headers,
+			ContentType:   msg.ContentType,
+			DeliveryMode:  amqp.Persistent,
+			CorrelationId: msg.CorrelationId,
+			MessageId:     msg.MessageId,
+			Timestamp:     msg.Timestamp,
+			Type:          msg.Type,
+			Body:          msg.Body,
+		},
+	)
+
+	if err != nil {
+		_ = msg.Nack(false, false)
+		return err
+	}
+
+	metrics.MessagesDeadLettered.Add(reason, 1)
+
+	return msg.Ack(false)
 }
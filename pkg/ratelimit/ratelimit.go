@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"kwekker-worker/pkg/config"
+	"kwekker-worker/pkg/db"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket rate limiter that keeps its buckets in Postgres, so that all
+// worker replicas share the same limits. Every key gets its own bucket holding at most
+// Burst tokens, which refills at KweksPerMinute tokens per minute.
+type Limiter struct {
+	mu     sync.RWMutex
+	config config.RateLimitConfig
+	// now tells the time buckets are refilled up to; tests replace it to let time pass.
+	now func() time.Time
+}
+
+func NewLimiter(config config.RateLimitConfig) *Limiter {
+	return &Limiter{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// SetConfig replaces the limits, for example when the configuration is reloaded. Buckets keep their tokens.
+func (l *Limiter) SetConfig(config config.RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+}
+
+// Allow takes a token from the bucket of key and reports whether one was available. tx should be the
+// transaction of what the token is taken for: the bucket stays locked until it ends, and the token is
+// given back when it is rolled back, so that retrying a failed transaction does not use up tokens.
+func (l *Limiter) Allow(ctx context.Context, tx db.Querier, key string) (bool, error) {
+	l.mu.RLock()
+	conf := l.config
+	l.mu.RUnlock()
+
+	if !conf.Enabled {
+		return true, nil
+	}
+
+	now := l.now()
+
+	// A new key starts with a full bucket. Inserting it first gives the query below a row to lock.
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO "RateLimits" ("Key", "Tokens", "UpdatedAt") VALUES ($1, $2, $3) ON CONFLICT ("Key") DO NOTHING`,
+		key,
+		float64(conf.Burst),
+		now,
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	var current bucket
+
+	err = tx.QueryRow(
+		ctx,
+		`SELECT "Tokens", "UpdatedAt" FROM "RateLimits" WHERE "Key" = $1 FOR UPDATE`,
+		key,
+	).Scan(&current.tokens, &current.updatedAt)
+
+	if err != nil {
+		return false, err
+	}
+
+	next, allowed := current.take(conf, now)
+
+	if !allowed {
+		return false, nil
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE "RateLimits" SET "Tokens" = $2, "UpdatedAt" = $3 WHERE "Key" = $1`,
+		key,
+		next.tokens,
+		next.updatedAt,
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// bucket is the number of tokens of a key at the time it was last updated.
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// take refills the bucket for the time that has passed since it was updated, and takes a token from it.
+// It reports false, and leaves the bucket as it was, when there is not a whole token to take.
+func (b bucket) take(conf config.RateLimitConfig, now time.Time) (bucket, bool) {
+	elapsed := now.Sub(b.updatedAt)
+
+	// Replicas whose clocks differ may have updated the bucket "in the future".
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	tokens := b.tokens + elapsed.Seconds()*conf.KweksPerMinute/60
+
+	if burst := float64(conf.Burst); tokens > burst {
+		tokens = burst
+	}
+
+	if tokens < 1 {
+		return b, false
+	}
+
+	return bucket{tokens: tokens - 1, updatedAt: now}, true
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"kwekker-worker/pkg/config"
+	"kwekker-worker/pkg/db/dbtest"
+	"testing"
+	"time"
+)
+
+var testConfig = config.RateLimitConfig{Enabled: true, KweksPerMinute: 60, Burst: 3}
+
+func TestBucketRefillsThroughTime(t *testing.T) {
+	start := time.Date(2022, 11, 2, 12, 0, 0, 0, time.UTC)
+	current := bucket{tokens: 3, updatedAt: start}
+
+	for i := 0; i < 3; i++ {
+		var allowed bool
+
+		if current, allowed = current.take(testConfig, start); !allowed {
+			t.Fatalf("Token %d of the burst should be taken, but is not", i+1)
+		}
+	}
+
+	steps := []struct {
+		after   time.Duration
+		allowed bool
+	}{
+		{0, false},
+		{500 * time.Millisecond, false},
+		{time.Second, true},
+		{time.Second, false},
+		{2500 * time.Millisecond, true},
+	}
+
+	for _, step := range steps {
+		var allowed bool
+
+		if current, allowed = current.take(testConfig, start.Add(step.after)); allowed != step.allowed {
+			t.Errorf("Taking a token after %s should be allowed: %t, but is %t", step.after, step.allowed, allowed)
+		}
+	}
+
+	if current.tokens < 0.49 || current.tokens > 0.51 {
+		t.Errorf("Half a token should be left after refilling at one token per second, but %f are", current.tokens)
+	}
+}
+
+func TestBucketRefillsUpToBurst(t *testing.T) {
+	start := time.Date(2022, 11, 2, 12, 0, 0, 0, time.UTC)
+	current, allowed := bucket{tokens: 0, updatedAt: start}.take(testConfig, start.Add(time.Hour))
+
+	if !allowed || current.tokens != 2 {
+		t.Errorf("A bucket idle for an hour should hold the burst of 3 tokens, and 2 after taking one, but holds %f", current.tokens)
+	}
+
+	// A replica whose clock runs behind does not take tokens away.
+	if _, allowed = current.take(testConfig, start); !allowed {
+		t.Errorf("Token should be taken at an earlier time than the bucket was updated, but is not")
+	}
+}
+
+// beginTestTransaction begins a transaction in the test database, which is rolled back when the test ends.
+func beginTestTransaction(t *testing.T) pgx.Tx {
+	ctx := context.Background()
+	tx, err := dbtest.Connect(t).Begin(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+
+	t.Cleanup(func() { tx.Rollback(ctx) })
+
+	return tx
+}
+
+func TestAllowLimitsEveryKeyOnItsOwn(t *testing.T) {
+	tx := beginTestTransaction(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	limiter := NewLimiter(testConfig)
+	limiter.now = func() time.Time { return now }
+
+	allow := func(key string) bool {
+		allowed, err := limiter.Allow(ctx, tx, key)
+
+		if err != nil {
+			t.Fatalf("Rate limit of %s should be checked, but is not: %v", key, err)
+		}
+
+		return allowed
+	}
+
+	for i := 0; i < 3; i++ {
+		if !allow("kwek:a") {
+			t.Fatalf("Kwek %d of the burst of a should be allowed, but is not", i+1)
+		}
+	}
+
+	if allow("kwek:a") {
+		t.Errorf("Kwek after the burst of a should not be allowed, but is")
+	}
+
+	if !allow("kwek:b") {
+		t.Errorf("Kwek of b should be allowed after a exhausted their bucket, but is not")
+	}
+
+	now = now.Add(time.Second)
+
+	if !allow("kwek:a") {
+		t.Errorf("Kwek of a should be allowed once their bucket refilled, but is not")
+	}
+}
+
+func TestAllowGivesTokenBackOnRollback(t *testing.T) {
+	tx := beginTestTransaction(t)
+	ctx := context.Background()
+	limiter := NewLimiter(config.RateLimitConfig{Enabled: true, KweksPerMinute: 0, Burst: 1})
+
+	// Beginning a transaction in a transaction creates a savepoint, which is rolled back like a failed attempt.
+	attempt, err := tx.Begin(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to begin savepoint: %v", err)
+	}
+
+	if allowed, err := limiter.Allow(ctx, attempt, "kwek:a"); !allowed || err != nil {
+		t.Fatalf("First kwek should be allowed, but is not: %v", err)
+	}
+
+	if err = attempt.Rollback(ctx); err != nil {
+		t.Fatalf("Failed to roll back savepoint: %v", err)
+	}
+
+	if allowed, err := limiter.Allow(ctx, tx, "kwek:a"); !allowed || err != nil {
+		t.Errorf("Kwek should be allowed after the attempt that took the only token was rolled back, but is not: %v", err)
+	}
+
+	if allowed, _ := limiter.Allow(ctx, tx, "kwek:a"); allowed {
+		t.Errorf("Kwek should not be allowed once the only token was taken, but is")
+	}
+}
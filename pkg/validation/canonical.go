@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"github.com/mtibben/confusables"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"net/mail"
+	"strings"
+	"unicode"
+)
+
+// CanonicalUsername returns the form of username that is used to tell whether two usernames are the
+// same. Invisible characters are removed, the username is case folded and look-alike characters are
+// mapped to their Unicode confusable skeleton, so "Alice", "alice" and "аlice" (with a Cyrillic a) all
+// share one canonical form. The result is only meant for comparison and should not be displayed.
+func CanonicalUsername(username string) string {
+	username = strings.Map(func(r rune) rune {
+		if unicode.In(r, unicode.Cf, unicode.Variation_Selector, unicode.Other_Default_Ignorable_Code_Point) {
+			return -1
+		}
+
+		return r
+	}, norm.NFKC.String(username))
+
+	// The skeleton maps some characters to upper case ones, such as "0" to "O", so fold again afterwards.
+	folded := cases.Fold().String(username)
+
+	return cases.Fold().String(confusables.Skeleton(folded))
+}
+
+// CanonicalEmail returns the form of email that is used to tell whether two addresses are the same:
+// the bare address with its domain in lower case. The local part is kept as is, since mail servers
+// are allowed to treat it as case-sensitive.
+func CanonicalEmail(email string) string {
+	if address, err := mail.ParseAddress(email); err == nil {
+		email = address.Address
+	}
+
+	at := strings.LastIndex(email, "@")
+
+	if at < 0 {
+		return email
+	}
+
+	return email[:at+1] + strings.TrimSuffix(strings.ToLower(email[at+1:]), ".")
+}
@@ -0,0 +1,40 @@
+package validation
+
+import "testing"
+
+func TestCanonicalUsernameFoldsLookAlikes(t *testing.T) {
+	usernames := []string{"Alice", "alice", "alice\u200b", "\u0430lice", "\uff21\uff2c\uff29\uff23\uff25"}
+
+	for _, username := range usernames {
+		if canonical := CanonicalUsername(username); canonical != CanonicalUsername("alice") {
+			t.Errorf("Canonical form of %q should equal that of \"alice\", but is %q", username, canonical)
+		}
+	}
+}
+
+func TestCanonicalUsernameFoldsDigitsThatLookLikeLetters(t *testing.T) {
+	if CanonicalUsername("b0b") != CanonicalUsername("bob") {
+		t.Errorf("Canonical form of \"b0b\" should equal that of \"bob\", but does not")
+	}
+}
+
+func TestCanonicalUsernameKeepsDistinctUsernamesApart(t *testing.T) {
+	if CanonicalUsername("alice") == CanonicalUsername("alicia") {
+		t.Errorf("Canonical forms of \"alice\" and \"alicia\" should differ, but do not")
+	}
+}
+
+func TestCanonicalEmail(t *testing.T) {
+	emails := map[string]string{
+		"Alice@Example.COM":             "Alice@example.com",
+		"alice@example.com.":            "alice@example.com",
+		"Alice <alice@Example.com>":     "alice@example.com",
+		"alice.smith@sub.EXAMPLE.co.uk": "alice.smith@sub.example.co.uk",
+	}
+
+	for email, expected := range emails {
+		if canonical := CanonicalEmail(email); canonical != expected {
+			t.Errorf("Canonical form of %q should be %q, but is %q", email, expected, canonical)
+		}
+	}
+}
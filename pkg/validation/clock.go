@@ -0,0 +1,16 @@
+package validation
+
+import (
+	"time"
+)
+
+// Clock provides the current time to the timestamp validators.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
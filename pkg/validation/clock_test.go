@@ -0,0 +1,103 @@
+package validation
+
+import (
+	"github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+var pinnedNow = time.Date(2022, time.November, 14, 12, 0, 0, 0, time.UTC)
+
+// pinnedPolicy returns the default policy with a clock that always tells pinnedNow.
+func pinnedPolicy() Policy {
+	policy := DefaultPolicy()
+	policy.Clock = fixedClock(pinnedNow)
+
+	return policy
+}
+
+func updateKwekAt(policy Policy, updatedAt time.Time) Validation {
+	return ValidateWith(&kwek.UpdateKwek{
+		KwekGuid:  "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:      "Hello world!",
+		UpdatedAt: timestamppb.New(updatedAt),
+	}, policy)
+}
+
+func TestValidateTimestampExactlyAtMaxAge(t *testing.T) {
+	t.Parallel()
+
+	validation := updateKwekAt(pinnedPolicy(), pinnedNow.Add(-30*24*time.Hour))
+
+	if !validation.Valid {
+		t.Errorf("Validation should be valid, but is not: %v", validation.Errors)
+	}
+}
+
+func TestValidateTimestampJustOverMaxAge(t *testing.T) {
+	t.Parallel()
+
+	validation := updateKwekAt(pinnedPolicy(), pinnedNow.Add(-30*24*time.Hour-time.Nanosecond))
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+
+	if len(validation.Errors) != 1 || validation.Errors[0].Code != CodeTooOld {
+		t.Errorf("Validation should have one %s error, but has %v", CodeTooOld, validation.Errors)
+	}
+}
+
+func TestValidateTimestampWithinFutureSkew(t *testing.T) {
+	t.Parallel()
+
+	validation := updateKwekAt(pinnedPolicy(), pinnedNow.Add(500*time.Millisecond))
+
+	if !validation.Valid {
+		t.Errorf("Validation should be valid, but is not: %v", validation.Errors)
+	}
+}
+
+func TestValidateTimestampExactlyAtFutureSkew(t *testing.T) {
+	t.Parallel()
+
+	validation := updateKwekAt(pinnedPolicy(), pinnedNow.Add(DefaultPolicy().TimestampFutureSkew))
+
+	if !validation.Valid {
+		t.Errorf("Validation should be valid, but is not: %v", validation.Errors)
+	}
+}
+
+func TestValidateTimestampBeyondFutureSkew(t *testing.T) {
+	t.Parallel()
+
+	validation := updateKwekAt(pinnedPolicy(), pinnedNow.Add(DefaultPolicy().TimestampFutureSkew+time.Nanosecond))
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+
+	if len(validation.Errors) != 1 || validation.Errors[0].Code != CodeInFuture {
+		t.Errorf("Validation should have one %s error, but has %v", CodeInFuture, validation.Errors)
+	}
+}
+
+func TestValidateTimestampWithoutFutureSkew(t *testing.T) {
+	t.Parallel()
+
+	policy := pinnedPolicy()
+	policy.TimestampFutureSkew = 0
+
+	validation := updateKwekAt(policy, pinnedNow.Add(time.Millisecond))
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+}
@@ -1,80 +1,107 @@
 package validation
 
 import (
-	"fmt"
-	"github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
-	"github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
 	"google.golang.org/protobuf/proto"
-	"google.golang.org/protobuf/types/known/timestamppb"
-	"time"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Validation struct {
 	Valid  bool
-	Errors []string
+	Errors Errors
 }
 
+func (v *Validation) addError(field string, code Code, params map[string]any) {
+	v.Valid = false
+	v.Errors = append(v.Errors, Error{Field: field, Code: code, Params: params})
+}
+
+// Validate checks every field of message against the rules declared for it in the rule table, with
+// the limits of the current policy. Optional fields are only checked when they are set to a non-empty
+// value, as handlers treat an empty value as unchanged. Messages of a type without rules are rejected.
 func Validate(message proto.Message) Validation {
-	switch message.(type) {
-	case *kwek.CreateKwek:
-		return ValidateCreateKwek(message.(*kwek.CreateKwek))
-	case *kwek.UpdateKwek:
-		return ValidateUpdateKwek(message.(*kwek.UpdateKwek))
-	case *kwek.DeleteKwek:
-		return ValidateDeleteKwek(message.(*kwek.DeleteKwek))
-	case *user.CreateUser:
-		return ValidateCreateUser(message.(*user.CreateUser))
-	case *user.UpdateUser:
-		return ValidateUpdateUser(message.(*user.UpdateUser))
-	case *user.DeleteUser:
-		return ValidateDeleteUser(message.(*user.DeleteUser))
-	default:
+	return ValidateWith(message, CurrentPolicy())
+}
+
+// ValidateWith validates message like Validate does, but with the limits and clock of policy.
+func ValidateWith(message proto.Message, policy Policy) Validation {
+	if message == nil || !validatedMessages[message.ProtoReflect().Descriptor().FullName()] {
 		return Validation{
 			Valid:  false,
-			Errors: []string{"Unknown message type"},
+			Errors: Errors{{Code: CodeUnknownType}},
 		}
 	}
-}
 
-func validateGuid(guid string, key string, v *Validation) {
-	if !assertNotEmpty(guid, key, v) {
-		return
+	validation := Validation{
+		Valid: true,
 	}
 
-	if len(guid) != 36 {
-		v.Valid = false
-		v.Errors = append(v.Errors, fmt.Sprintf("%s must be a valid GUID", key))
-	}
-}
+	reflection := message.ProtoReflect()
+	fields := reflection.Descriptor().Fields()
 
-func validateUpdatedAt(updatedAt *timestamppb.Timestamp, v *Validation) {
-	validateTimestamp(updatedAt, "UpdatedAt", v)
-}
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		rules, ok := ruleTable[field.FullName()]
 
-func validateTimestamp(timestamp *timestamppb.Timestamp, key string, v *Validation) {
-	if timestamp == nil {
-		v.Valid = false
-		v.Errors = append(v.Errors, fmt.Sprintf("%s is required", key))
+		if !ok {
+			continue
+		}
 
-		return
-	}
+		value := fieldValue{label: label(field), value: reflection.Get(field), present: reflection.Has(field)}
+
+		if field.HasOptionalKeyword() && (!value.present || field.Kind() == protoreflect.StringKind && value.value.String() == "") {
+			continue
+		}
 
-	if timestamp.AsTime().After(time.Now()) {
-		v.Valid = false
-		v.Errors = append(v.Errors, fmt.Sprintf("%s cannot be in the future", key))
-	} else if timestamp.AsTime().Before(time.Now().Add(-24 * 30 * time.Hour)) {
-		v.Valid = false
-		v.Errors = append(v.Errors, fmt.Sprintf("%s cannot be more than 30 days ago", key))
+		for _, rule := range rules {
+			if !rule.check(value, policy, &validation) {
+				break
+			}
+		}
 	}
+
+	return validation
 }
 
 func assertNotEmpty(value string, key string, v *Validation) bool {
 	if value == "" {
-		v.Valid = false
-		v.Errors = append(v.Errors, key+" is required")
+		v.addError(key, CodeRequired, nil)
 
 		return false
 	}
 
 	return true
 }
+
+// length returns the number of user-perceived characters (grapheme clusters) in value, so that
+// an emoji or a letter with combining accents counts as one character regardless of its byte size.
+func length(value string) int {
+	return uniseg.GraphemeClusterCount(norm.NFC.String(value))
+}
+
+// validateCharacters checks that value is valid UTF-8 and contains no control characters.
+// Line breaks and tabs are only accepted when allowLineBreaks is set.
+func validateCharacters(value string, key string, allowLineBreaks bool, v *Validation) bool {
+	if !utf8.ValidString(value) {
+		v.addError(key, CodeInvalidUTF8, nil)
+
+		return false
+	}
+
+	for _, char := range value {
+		if allowLineBreaks && (char == '\n' || char == '\r' || char == '\t') {
+			continue
+		}
+
+		if unicode.IsControl(char) {
+			v.addError(key, CodeControlCharacter, nil)
+
+			return false
+		}
+	}
+
+	return true
+}
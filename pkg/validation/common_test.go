@@ -0,0 +1,78 @@
+package validation
+
+import (
+	"github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+)
+
+func TestLengthCountsGraphemes(t *testing.T) {
+	lengths := map[string]int{
+		"":                     0,
+		"abc":                  3,
+		"e\u0301":              1,
+		"\U0001f44d\U0001f3fd": 1,
+		"\U0001f1f3\U0001f1f1": 1,
+		"ж\r\n":                2,
+	}
+
+	for value, expected := range lengths {
+		if actual := length(value); actual != expected {
+			t.Errorf("Length of %q should be %d, but is %d", value, expected, actual)
+		}
+	}
+}
+
+func TestValidateCharactersWithLineBreaks(t *testing.T) {
+	v := Validation{Valid: true}
+
+	if !validateCharacters("a\r\n\tb", "Text", true, &v) || !v.Valid {
+		t.Errorf("Line breaks should be accepted when allowed, but are not: %v", v.Errors)
+	}
+
+	v = Validation{Valid: true}
+
+	if validateCharacters("a\nb", "Username", false, &v) || v.Valid {
+		t.Errorf("Line breaks should be rejected when not allowed, but are not")
+	}
+
+	if len(v.Errors) != 1 || v.Errors[0].Code != CodeControlCharacter {
+		t.Errorf("Validation should have one %s error, but has %v", CodeControlCharacter, v.Errors)
+	}
+}
+
+func TestAssertNotEmpty(t *testing.T) {
+	v := Validation{Valid: true}
+
+	if assertNotEmpty("", "UserId", &v) || v.Valid {
+		t.Errorf("Empty value should be rejected, but is not")
+	}
+
+	if len(v.Errors) != 1 || v.Errors[0].Field != "UserId" || v.Errors[0].Code != CodeRequired {
+		t.Errorf("Validation should have one %s error for UserId, but has %v", CodeRequired, v.Errors)
+	}
+}
+
+func TestValidateDeleteUserWithoutUserId(t *testing.T) {
+	validation := ValidateDeleteUser(&user.DeleteUser{})
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+
+	if len(validation.Errors) != 1 {
+		t.Errorf("Validation should have one error, but has %d", len(validation.Errors))
+	}
+}
+
+func TestValidateUnknownType(t *testing.T) {
+	validation := Validate(timestamppb.Now())
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+
+	if len(validation.Errors) != 1 || validation.Errors[0].Code != CodeUnknownType {
+		t.Errorf("Validation should have one %s error, but has %v", CodeUnknownType, validation.Errors)
+	}
+}
@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Code is a machine-readable reason for a validation error.
+type Code string
+
+const (
+	CodeRequired           Code = "REQUIRED"
+	CodeTooShort           Code = "TOO_SHORT"
+	CodeTooLong            Code = "TOO_LONG"
+	CodeInvalidFormat      Code = "INVALID_FORMAT"
+	CodeUnsupportedVersion Code = "UNSUPPORTED_VERSION"
+	CodeInvalidUTF8        Code = "INVALID_UTF8"
+	CodeControlCharacter   Code = "CONTROL_CHARACTER"
+	CodeInvalidScheme      Code = "INVALID_SCHEME"
+	CodeHasCredentials     Code = "HAS_CREDENTIALS"
+	CodeInvalidPort        Code = "INVALID_PORT"
+	CodeHostNotAllowed     Code = "HOST_NOT_ALLOWED"
+	CodeInFuture           Code = "IN_FUTURE"
+	CodeTooOld             Code = "TOO_OLD"
+	CodeReserved           Code = "RESERVED"
+	CodeUnknownType        Code = "UNKNOWN_TYPE"
+)
+
+// Error describes why a single field failed validation. Params holds the values the rule was checked
+// against, such as "max" for CodeTooLong, so that consumers can build their own messages.
+type Error struct {
+	Field  string         `json:"field"`
+	Code   Code           `json:"code"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// Error returns an English description of the error, intended for logs.
+func (e Error) Error() string {
+	switch e.Code {
+	case CodeRequired:
+		return fmt.Sprintf("%s is required", e.Field)
+	case CodeTooShort:
+		return fmt.Sprintf("%s must be at least %v characters", e.Field, e.Params["min"])
+	case CodeTooLong:
+		return fmt.Sprintf("%s must be less than %v characters", e.Field, e.Params["max"])
+	case CodeInvalidFormat:
+		return fmt.Sprintf("%s must be a valid %v", e.Field, e.Params["format"])
+	case CodeUnsupportedVersion:
+		return fmt.Sprintf("%s must be a GUID of version %v", e.Field, e.Params["versions"])
+	case CodeInvalidUTF8:
+		return fmt.Sprintf("%s must be valid UTF-8", e.Field)
+	case CodeControlCharacter:
+		return fmt.Sprintf("%s cannot contain control characters", e.Field)
+	case CodeInvalidScheme:
+		return fmt.Sprintf("%s must start with %v://", e.Field, e.Params["scheme"])
+	case CodeHasCredentials:
+		return fmt.Sprintf("%s cannot contain credentials", e.Field)
+	case CodeInvalidPort:
+		return fmt.Sprintf("%s cannot specify a port", e.Field)
+	case CodeHostNotAllowed:
+		return fmt.Sprintf("%s cannot point to host %v", e.Field, e.Params["host"])
+	case CodeInFuture:
+		return fmt.Sprintf("%s cannot be in the future", e.Field)
+	case CodeTooOld:
+		return fmt.Sprintf("%s cannot be more than %v days ago", e.Field, e.Params["maxAgeDays"])
+	case CodeReserved:
+		return fmt.Sprintf("%s is reserved", e.Field)
+	case CodeUnknownType:
+		return "Unknown message type"
+	default:
+		return fmt.Sprintf("%s is invalid (%s)", e.Field, e.Code)
+	}
+}
+
+// Errors is the list of errors of a failed validation. It can be serialised as JSON.
+type Errors []Error
+
+// Messages returns the English description of every error.
+func (e Errors) Messages() []string {
+	messages := make([]string, 0, len(e))
+
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return messages
+}
+
+func (e Errors) Error() string {
+	return strings.Join(e.Messages(), "; ")
+}
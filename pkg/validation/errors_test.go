@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"encoding/json"
+	"github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateCreateKwekReportsFieldAndCode(t *testing.T) {
+	createKwek := kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     strings.Repeat("a", 257),
+		UserId:   "",
+		PostedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix() + 100},
+	}
+
+	validation := ValidateCreateKwek(&createKwek)
+
+	expected := Errors{
+		{Field: "Text", Code: CodeTooLong, Params: map[string]any{"max": 256}},
+		{Field: "UserId", Code: CodeRequired},
+		{Field: "PostedAt", Code: CodeInFuture, Params: map[string]any{"allowedSkew": "2s"}},
+	}
+
+	if !reflect.DeepEqual(validation.Errors, expected) {
+		t.Errorf("Validation should have errors %v, but has %v", expected, validation.Errors)
+	}
+}
+
+func TestErrorsMessages(t *testing.T) {
+	errors := Errors{
+		{Field: "Text", Code: CodeTooLong, Params: map[string]any{"max": 256}},
+		{Field: "Username", Code: CodeTooShort, Params: map[string]any{"min": 3}},
+		{Field: "KwekGuid", Code: CodeInvalidFormat, Params: map[string]any{"format": "GUID"}},
+		{Field: "AvatarUrl", Code: CodeInvalidScheme, Params: map[string]any{"scheme": "https"}},
+		{Field: "PostedAt", Code: CodeTooOld, Params: map[string]any{"maxAgeDays": 30}},
+		{Field: "UserId", Code: CodeRequired},
+	}
+
+	expected := []string{
+		"Text must be less than 256 characters",
+		"Username must be at least 3 characters",
+		"KwekGuid must be a valid GUID",
+		"AvatarUrl must start with https://",
+		"PostedAt cannot be more than 30 days ago",
+		"UserId is required",
+	}
+
+	if !reflect.DeepEqual(errors.Messages(), expected) {
+		t.Errorf("Messages should be %v, but are %v", expected, errors.Messages())
+	}
+}
+
+func TestErrorsMarshalJSON(t *testing.T) {
+	errors := Errors{
+		{Field: "Text", Code: CodeTooLong, Params: map[string]any{"max": 256}},
+		{Field: "UserId", Code: CodeRequired},
+	}
+
+	encoded, err := json.Marshal(errors)
+
+	if err != nil {
+		t.Fatalf("Errors should be serialisable, but are not: %v", err)
+	}
+
+	expected := `[{"field":"Text","code":"TOO_LONG","params":{"max":256}},{"field":"UserId","code":"REQUIRED"}]`
+
+	if string(encoded) != expected {
+		t.Errorf("Errors should be serialised as %s, but are %s", expected, encoded)
+	}
+}
@@ -0,0 +1,181 @@
+package validation
+
+import (
+	"github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+// checkInvariants reports a violation of the properties that hold for every validation, whether the
+// message is valid or not: Valid is set exactly when there are no errors and every error describes itself.
+func checkInvariants(t *testing.T, validation Validation) {
+	t.Helper()
+
+	if validation.Valid != (len(validation.Errors) == 0) {
+		t.Errorf("Validation should be valid exactly when it has no errors, but is %t with errors %v", validation.Valid, validation.Errors)
+	}
+
+	for _, err := range validation.Errors {
+		if err.Code == "" || err.Error() == "" {
+			t.Errorf("Validation error should have a code and a message, but is %#v", err)
+		}
+	}
+}
+
+// checkIdempotent reports whether normalizing the already normalized message changes it again.
+func checkIdempotent(t *testing.T, normalized proto.Message) {
+	t.Helper()
+
+	renormalized := proto.Clone(normalized)
+	Normalize(renormalized)
+
+	if !proto.Equal(normalized, renormalized) {
+		t.Errorf("Normalizing a normalized message should not change it, but changed %v to %v", normalized, renormalized)
+	}
+}
+
+// wireSeeds are edge cases of the wire format that mean the same for every message type.
+var wireSeeds = [][]byte{
+	{},                     // a message without fields
+	{0x08, 0xff},           // field 1 as a varint that never ends
+	{0x0a, 0x10, 'a', 'b'}, // field 1 shorter than its length says
+	{0xf8, 0x07, 0x01},     // field 127, which no message has
+}
+
+// invalidUTF8 returns a message whose string field number holds bytes that are not UTF-8. They cannot be
+// marshalled, so such inputs only reach the fuzz target as raw bytes.
+func invalidUTF8(number protowire.Number) []byte {
+	data := protowire.AppendTag(nil, number, protowire.BytesType)
+
+	return protowire.AppendBytes(data, []byte{0xff, 0xfe})
+}
+
+// fuzzValidate feeds arbitrary bytes through the same steps the worker applies to a delivery:
+// unmarshalling into the type of prototype, normalising and validating. The seeds are marshalled
+// and added to the corpus, next to the wire format edge cases shared by every message type.
+func fuzzValidate(f *testing.F, prototype proto.Message, seeds ...proto.Message) {
+	for _, seed := range seeds {
+		data, err := proto.Marshal(seed)
+
+		if err != nil {
+			f.Fatalf("Seed should marshal, but does not: %v", err)
+		}
+
+		f.Add(data)
+	}
+
+	for _, data := range wireSeeds {
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		message := prototype.ProtoReflect().New().Interface()
+
+		if err := proto.Unmarshal(data, message); err != nil {
+			return
+		}
+
+		Normalize(message)
+		checkIdempotent(t, message)
+		checkInvariants(t, Validate(message))
+	})
+}
+
+func FuzzValidateCreateKwek(f *testing.F) {
+	f.Add(invalidUTF8(2))
+
+	fuzzValidate(
+		f,
+		&kwek.CreateKwek{},
+		&kwek.CreateKwek{
+			KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+			Text:     "Café #kwek @alice",
+			UserId:   "123",
+			PostedAt: timestamppb.Now(),
+		},
+		&kwek.CreateKwek{KwekGuid: "F9D30D3763A844A9B2C33A45EB0701BC", Text: "Uppercase GUID without dashes"},
+		&kwek.CreateKwek{Text: "Cafe\u0301 with a decomposed accent"},
+		&kwek.CreateKwek{Text: "Zero\u200bwidth space and a\x00control character"},
+		&kwek.CreateKwek{UserId: "\u200b", PostedAt: &timestamppb.Timestamp{Seconds: -1, Nanos: -1}},
+	)
+}
+
+func FuzzValidateUpdateKwek(f *testing.F) {
+	f.Add(invalidUTF8(2))
+
+	fuzzValidate(
+		f,
+		&kwek.UpdateKwek{},
+		&kwek.UpdateKwek{
+			KwekGuid:  "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+			Text:      "Hello\nworld!",
+			UpdatedAt: timestamppb.New(time.Now().Add(-time.Hour)),
+		},
+		&kwek.UpdateKwek{KwekGuid: "F9D30D3763A844A9B2C33A45EB0701BC", Text: "e\u0301\u200b\x07"},
+		&kwek.UpdateKwek{UpdatedAt: timestamppb.New(time.Now().Add(time.Hour))},
+	)
+}
+
+func FuzzValidateDeleteKwek(f *testing.F) {
+	f.Add(invalidUTF8(1))
+
+	fuzzValidate(
+		f,
+		&kwek.DeleteKwek{},
+		&kwek.DeleteKwek{KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc"},
+		&kwek.DeleteKwek{KwekGuid: "F9D30D3763A844A9B2C33A45EB0701BC"},
+		&kwek.DeleteKwek{KwekGuid: " f9d30d37-63a8-44a9-b2c3-3a45eb0701bc\u200b"},
+	)
+}
+
+func FuzzValidateCreateUser(f *testing.F) {
+	f.Add(invalidUTF8(2))
+
+	decomposed := validCreateUser()
+	decomposed.Username = "e\u0301lise"
+	decomposed.DisplayName = "E\u0301lise"
+
+	invisible := validCreateUser()
+	invisible.Username = "ali\u200bce"
+	invisible.Email = "Alice@EXAMPLE.com."
+	invisible.AvatarUrl = "https://example.com/\x00.png"
+
+	fuzzValidate(f, &user.CreateUser{}, validCreateUser(), decomposed, invisible)
+}
+
+func FuzzValidateUpdateUser(f *testing.F) {
+	f.Add(invalidUTF8(2))
+
+	displayName := "Älice"
+	avatarUrl := "https://cdn.example.com:443/alice.png"
+	username := "ali\u200bce"
+	email := "\"Alice\" <alice@EXAMPLE.com>"
+
+	fuzzValidate(
+		f,
+		&user.UpdateUser{},
+		&user.UpdateUser{
+			UserId:      "553bb4d0-332e-401f-8e9d-e44b44aa0532",
+			DisplayName: &displayName,
+			AvatarUrl:   &avatarUrl,
+			UpdatedAt:   timestamppb.Now(),
+		},
+		&user.UpdateUser{UserId: "553BB4D0332E401F8E9DE44B44AA0532", Username: &username, Email: &email},
+	)
+}
+
+func FuzzValidateDeleteUser(f *testing.F) {
+	f.Add(invalidUTF8(1))
+
+	fuzzValidate(
+		f,
+		&user.DeleteUser{},
+		&user.DeleteUser{UserId: "553bb4d0-332e-401f-8e9d-e44b44aa0532"},
+		&user.DeleteUser{UserId: "553BB4D0332E401F8E9DE44B44AA0532"},
+		&user.DeleteUser{UserId: "\u200b"},
+	)
+}
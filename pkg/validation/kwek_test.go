@@ -444,3 +444,199 @@ func TestValidateDeleteKwekWithInvalidKwekGuid(t *testing.T) {
 		t.Errorf("Validation should have one error, but has %d", len(validation.Errors))
 	}
 }
+
+func TestValidateCreateKwekWithMaxLengthEmojiText(t *testing.T) {
+	createKwek := kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     strings.Repeat("👍🏽", 256),
+		UserId:   "123",
+		PostedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+	}
+
+	validation := ValidateCreateKwek(&createKwek)
+
+	if !validation.Valid {
+		t.Errorf("Validation should be valid, but is not: %v", validation.Errors)
+	}
+}
+
+func TestValidateCreateKwekWithTooLongEmojiText(t *testing.T) {
+	createKwek := kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     strings.Repeat("👨‍👩‍👧", 257),
+		UserId:   "123",
+		PostedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+	}
+
+	validation := ValidateCreateKwek(&createKwek)
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+
+	if len(validation.Errors) != 1 {
+		t.Errorf("Validation should have one error, but has %d", len(validation.Errors))
+	}
+}
+
+func TestValidateCreateKwekWithMaxLengthCyrillicText(t *testing.T) {
+	createKwek := kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     strings.Repeat("Ж", 256),
+		UserId:   "123",
+		PostedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+	}
+
+	validation := ValidateCreateKwek(&createKwek)
+
+	if !validation.Valid {
+		t.Errorf("Validation should be valid, but is not: %v", validation.Errors)
+	}
+}
+
+func TestValidateCreateKwekWithMaxLengthCJKText(t *testing.T) {
+	createKwek := kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     strings.Repeat("語", 256),
+		UserId:   "123",
+		PostedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+	}
+
+	validation := ValidateCreateKwek(&createKwek)
+
+	if !validation.Valid {
+		t.Errorf("Validation should be valid, but is not: %v", validation.Errors)
+	}
+}
+
+func TestValidateCreateKwekWithMaxLengthDecomposedText(t *testing.T) {
+	createKwek := kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     strings.Repeat("e\u0301", 256),
+		UserId:   "123",
+		PostedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+	}
+
+	validation := ValidateCreateKwek(&createKwek)
+
+	if !validation.Valid {
+		t.Errorf("Validation should be valid, but is not: %v", validation.Errors)
+	}
+}
+
+func TestValidateCreateKwekWithLineBreaks(t *testing.T) {
+	createKwek := kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     "Hello\r\nworld!\tBye",
+		UserId:   "123",
+		PostedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+	}
+
+	validation := ValidateCreateKwek(&createKwek)
+
+	if !validation.Valid {
+		t.Errorf("Validation should be valid, but is not: %v", validation.Errors)
+	}
+}
+
+func TestValidateCreateKwekWithControlCharacters(t *testing.T) {
+	createKwek := kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     "Hello\x00world\x1b[31m",
+		UserId:   "123",
+		PostedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+	}
+
+	validation := ValidateCreateKwek(&createKwek)
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+
+	if len(validation.Errors) != 1 {
+		t.Errorf("Validation should have one error, but has %d", len(validation.Errors))
+	}
+}
+
+func TestValidateCreateKwekWithInvalidUTF8(t *testing.T) {
+	createKwek := kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     "Hello \xff\xfe world",
+		UserId:   "123",
+		PostedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+	}
+
+	validation := ValidateCreateKwek(&createKwek)
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+
+	if len(validation.Errors) != 1 {
+		t.Errorf("Validation should have one error, but has %d", len(validation.Errors))
+	}
+}
+
+func TestValidateDeleteKwekWithHyphenOnlyGuid(t *testing.T) {
+	deleteKwek := kwek.DeleteKwek{
+		KwekGuid: "------------------------------------",
+	}
+
+	validation := ValidateDeleteKwek(&deleteKwek)
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+
+	if len(validation.Errors) != 1 {
+		t.Errorf("Validation should have one error, but has %d", len(validation.Errors))
+	}
+}
+
+func TestValidateDeleteKwekWithNilGuid(t *testing.T) {
+	deleteKwek := kwek.DeleteKwek{
+		KwekGuid: "00000000-0000-0000-0000-000000000000",
+	}
+
+	validation := ValidateDeleteKwek(&deleteKwek)
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+}
+
+func TestValidateDeleteKwekWithUnsupportedGuidVersion(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.GuidVersions = []int{4, 7}
+	usePolicy(t, policy)
+
+	deleteKwek := kwek.DeleteKwek{
+		KwekGuid: "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	}
+
+	validation := ValidateDeleteKwek(&deleteKwek)
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+
+	if len(validation.Errors) != 1 || validation.Errors[0].Code != CodeUnsupportedVersion {
+		t.Errorf("Validation should have one %s error, but has %v", CodeUnsupportedVersion, validation.Errors)
+	}
+}
+
+func TestValidateDeleteKwekWithSupportedGuidVersion(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.GuidVersions = []int{4, 7}
+	usePolicy(t, policy)
+
+	deleteKwek := kwek.DeleteKwek{
+		KwekGuid: "018463a4-3ab1-7b5c-9c7e-2b7a34e0f1d2",
+	}
+
+	validation := ValidateDeleteKwek(&deleteKwek)
+
+	if !validation.Valid {
+		t.Errorf("Validation should be valid, but is not: %v", validation.Errors)
+	}
+}
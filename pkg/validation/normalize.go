@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Normalize rewrites every string field of message to Unicode normalization form C, so that
+// visually identical input is validated and stored the same way, and writes GUIDs in their
+// canonical lowercase hyphenated form. It should be called before Validate.
+func Normalize(message proto.Message) {
+	reflection := message.ProtoReflect()
+
+	reflection.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		if field.Kind() != protoreflect.StringKind || field.IsList() || field.IsMap() {
+			return true
+		}
+
+		normalized := value.String()
+
+		for _, rule := range ruleTable[field.FullName()] {
+			if rule.normalize != nil {
+				normalized = rule.normalize(normalized)
+			}
+		}
+
+		if normalized = norm.NFC.String(normalized); normalized != value.String() {
+			reflection.Set(field, protoreflect.ValueOfString(normalized))
+		}
+
+		return true
+	})
+}
+
+// canonicalGuid returns guid in lowercase hyphenated form, or guid itself if it cannot be parsed.
+func canonicalGuid(guid string) string {
+	parsed, err := uuid.Parse(guid)
+
+	if err != nil {
+		return guid
+	}
+
+	return parsed.String()
+}
@@ -0,0 +1,69 @@
+package validation
+
+import (
+	"github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"testing"
+)
+
+func TestNormalizeComposesText(t *testing.T) {
+	createKwek := kwek.CreateKwek{
+		Text: "Cafe\u0301",
+	}
+
+	Normalize(&createKwek)
+
+	if createKwek.GetText() != "Caf\u00e9" {
+		t.Errorf("Text should be normalized to NFC, but is %q", createKwek.GetText())
+	}
+}
+
+func TestNormalizeComposesOptionalFields(t *testing.T) {
+	username := "Zoe\u0308"
+	updateUser := user.UpdateUser{
+		Username: &username,
+	}
+
+	Normalize(&updateUser)
+
+	if updateUser.GetUsername() != "Zo\u00eb" {
+		t.Errorf("Username should be normalized to NFC, but is %q", updateUser.GetUsername())
+	}
+
+	if updateUser.DisplayName != nil {
+		t.Errorf("Unset optional fields should stay unset")
+	}
+}
+
+func TestNormalizeCanonicalisesGuid(t *testing.T) {
+	guids := []string{
+		"F9D30D37-63A8-44A9-B2C3-3A45EB0701BC",
+		"{f9d30d37-63a8-44a9-b2c3-3a45eb0701bc}",
+		"urn:uuid:f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		"f9d30d3763a844a9b2c33a45eb0701bc",
+	}
+
+	for _, guid := range guids {
+		deleteKwek := kwek.DeleteKwek{
+			KwekGuid: guid,
+		}
+
+		Normalize(&deleteKwek)
+
+		if deleteKwek.GetKwekGuid() != "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc" {
+			t.Errorf("GUID %q should be canonicalised, but is %q", guid, deleteKwek.GetKwekGuid())
+		}
+	}
+}
+
+func TestNormalizeKeepsInvalidGuid(t *testing.T) {
+	deleteKwek := kwek.DeleteKwek{
+		KwekGuid: "invalid",
+	}
+
+	Normalize(&deleteKwek)
+
+	if deleteKwek.GetKwekGuid() != "invalid" {
+		t.Errorf("Invalid GUID should be left unchanged, but is %q", deleteKwek.GetKwekGuid())
+	}
+}
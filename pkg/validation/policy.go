@@ -0,0 +1,138 @@
+package validation
+
+import (
+	"fmt"
+	"kwekker-worker/pkg/config"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Policy holds the limits that the validators check against.
+type Policy struct {
+	TextMaxLength        int
+	UsernameMinLength    int
+	UsernameMaxLength    int
+	DisplayNameMaxLength int
+	AvatarUrlMaxLength   int
+	TimestampMaxAgeDays  int
+	// TimestampFutureSkew is how far in the future a timestamp may be before it is rejected.
+	TimestampFutureSkew time.Duration
+	// GuidVersions restricts the accepted GUID versions, e.g. []int{4, 7}. Any version is accepted when empty.
+	GuidVersions []int
+	// ReservedUsernames cannot be registered, nor can any username with the same canonical form.
+	ReservedUsernames []string
+	// AvatarHosts restricts the hosts avatars may be served from. An entry starting with a dot, such as
+	// ".gravatar.com", matches the domain and all of its subdomains. Any public host is accepted when empty.
+	AvatarHosts []string
+	// Clock tells the time timestamps are checked against; the system clock is used when it is nil.
+	Clock Clock
+}
+
+// now returns the current time according to the clock of the policy.
+func (p Policy) now() time.Time {
+	if p.Clock == nil {
+		return systemClock{}.Now()
+	}
+
+	return p.Clock.Now()
+}
+
+// DefaultPolicy returns the policy of the default validation settings.
+func DefaultPolicy() Policy {
+	return NewPolicy(config.DefaultValidationConfig())
+}
+
+func NewPolicy(config config.ValidationConfig) Policy {
+	return Policy{
+		TextMaxLength:        config.TextMaxLength,
+		UsernameMinLength:    config.UsernameMinLength,
+		UsernameMaxLength:    config.UsernameMaxLength,
+		DisplayNameMaxLength: config.DisplayNameMaxLength,
+		AvatarUrlMaxLength:   config.AvatarUrlMaxLength,
+		TimestampMaxAgeDays:  config.TimestampMaxAgeDays,
+		TimestampFutureSkew:  config.TimestampFutureSkew,
+		GuidVersions:         config.GuidVersions,
+		ReservedUsernames:    config.ReservedUsernames,
+		AvatarHosts:          config.AvatarHosts,
+	}
+}
+
+// Check returns an error describing every limit that is out of range or contradicts another limit.
+func (p Policy) Check() error {
+	problems := make([]string, 0)
+
+	limits := []struct {
+		name  string
+		value int
+	}{
+		{"text maximum length", p.TextMaxLength},
+		{"username maximum length", p.UsernameMaxLength},
+		{"display name maximum length", p.DisplayNameMaxLength},
+		{"avatar URL maximum length", p.AvatarUrlMaxLength},
+		{"timestamp maximum age", p.TimestampMaxAgeDays},
+	}
+
+	for _, limit := range limits {
+		if limit.value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be positive, but is %d", limit.name, limit.value))
+		}
+	}
+
+	if p.TimestampFutureSkew < 0 {
+		problems = append(problems, fmt.Sprintf("timestamp future skew cannot be negative, but is %s", p.TimestampFutureSkew))
+	}
+
+	for _, version := range p.GuidVersions {
+		if version < 1 || version > 8 {
+			problems = append(problems, fmt.Sprintf("GUID version must be between 1 and 8, but is %d", version))
+		}
+	}
+
+	for _, host := range p.AvatarHosts {
+		if strings.Trim(host, ".") == "" || strings.ContainsAny(host, "/:@ ") {
+			problems = append(problems, fmt.Sprintf("avatar host must be a hostname or a .suffix, but is %q", host))
+		}
+	}
+
+	if p.UsernameMinLength < 1 {
+		problems = append(problems, fmt.Sprintf("username minimum length must be at least 1, but is %d", p.UsernameMinLength))
+	}
+
+	if p.UsernameMinLength > p.UsernameMaxLength {
+		problems = append(problems, fmt.Sprintf(
+			"username minimum length (%d) cannot be greater than its maximum length (%d)",
+			p.UsernameMinLength,
+			p.UsernameMaxLength,
+		))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid validation policy: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+var currentPolicy atomic.Pointer[Policy]
+
+func init() {
+	policy := DefaultPolicy()
+	currentPolicy.Store(&policy)
+}
+
+// SetPolicy replaces the policy used by all validators, after checking it for contradictions.
+// It is safe to call while messages are being validated.
+func SetPolicy(policy Policy) error {
+	if err := policy.Check(); err != nil {
+		return err
+	}
+
+	currentPolicy.Store(&policy)
+
+	return nil
+}
+
+func CurrentPolicy() Policy {
+	return *currentPolicy.Load()
+}
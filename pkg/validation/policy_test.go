@@ -0,0 +1,103 @@
+package validation
+
+import (
+	"github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// usePolicy applies policy for the duration of a test.
+func usePolicy(t *testing.T, policy Policy) {
+	previous := CurrentPolicy()
+
+	if err := SetPolicy(policy); err != nil {
+		t.Fatalf("Policy should be accepted, but was not: %v", err)
+	}
+
+	t.Cleanup(func() { _ = SetPolicy(previous) })
+}
+
+func TestDefaultPolicyIsValid(t *testing.T) {
+	if err := DefaultPolicy().Check(); err != nil {
+		t.Errorf("Default policy should be valid, but is not: %v", err)
+	}
+}
+
+func TestPolicyWithUsernameMinGreaterThanMax(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.UsernameMinLength = 20
+
+	if err := policy.Check(); err == nil {
+		t.Errorf("Policy should be invalid, but is not")
+	}
+}
+
+func TestPolicyWithNonPositiveLimits(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.TextMaxLength = 0
+	policy.TimestampMaxAgeDays = -1
+
+	err := policy.Check()
+
+	if err == nil {
+		t.Fatalf("Policy should be invalid, but is not")
+	}
+
+	if !strings.Contains(err.Error(), "text maximum length") || !strings.Contains(err.Error(), "timestamp maximum age") {
+		t.Errorf("Error should mention every invalid limit, but is %q", err.Error())
+	}
+}
+
+func TestSetPolicyRejectsInvalidPolicy(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.UsernameMaxLength = 0
+
+	if err := SetPolicy(policy); err == nil {
+		t.Errorf("Invalid policy should be rejected, but was not")
+	}
+
+	if !reflect.DeepEqual(CurrentPolicy(), DefaultPolicy()) {
+		t.Errorf("Current policy should be unchanged after a rejected policy")
+	}
+}
+
+func TestValidateCreateKwekWithCustomTextMaxLength(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.TextMaxLength = 10
+	usePolicy(t, policy)
+
+	createKwek := kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     strings.Repeat("a", 11),
+		UserId:   "123",
+		PostedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+	}
+
+	validation := ValidateCreateKwek(&createKwek)
+
+	if validation.Valid {
+		t.Errorf("Validation should be invalid, but is not")
+	}
+
+	if len(validation.Errors) != 1 || validation.Errors[0].Params["max"] != 10 {
+		t.Errorf("Validation should report the configured limit, but reports %v", validation.Errors)
+	}
+}
+
+func TestPolicyWithInvalidAvatarHost(t *testing.T) {
+	policy := DefaultPolicy()
+	policy.AvatarHosts = []string{"https://cdn.kwekker.com", "."}
+
+	err := policy.Check()
+
+	if err == nil {
+		t.Fatalf("Policy should be invalid, but is not")
+	}
+
+	if strings.Count(err.Error(), "avatar host") != 2 {
+		t.Errorf("Error should mention both avatar hosts, but is %q", err)
+	}
+}
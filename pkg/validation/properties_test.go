@@ -0,0 +1,141 @@
+package validation
+
+import (
+	"github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"google.golang.org/protobuf/proto"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// fragments are the pieces that random field values are built from. They are chosen to reach the edge
+// cases of the validators: decomposed and compatibility characters, invisible and control characters,
+// invalid UTF-8, and the syntax of GUIDs, email addresses and URLs.
+var fragments = []string{
+	"a", "Z", "0", "ж", "\U0001f44d\U0001f3fd", "\u00e9", "e\u0301", "\ufb01", "\uff21", "\u200b",
+	"\u0000", "\n", "\t", " ", "\xff", "@", ".", "-", ":", "/", "https://", "example.com", "localhost",
+	"f9d30d37", "63a8",
+}
+
+// fragmentString is a random value assembled from fragments, for use with testing/quick.
+type fragmentString string
+
+func (fragmentString) Generate(r *rand.Rand, size int) reflect.Value {
+	var builder strings.Builder
+
+	for i := r.Intn(size + 1); i > 0; i-- {
+		builder.WriteString(fragments[r.Intn(len(fragments))])
+	}
+
+	return reflect.ValueOf(fragmentString(builder.String()))
+}
+
+// pick returns the random value when its bit in keep is unset, and the valid value otherwise, so that
+// roughly half of the generated fields are valid and whole messages are valid often enough to matter.
+func pick(keep uint8, bit int, random fragmentString, valid string) string {
+	if keep&(1<<bit) != 0 {
+		return valid
+	}
+
+	return string(random)
+}
+
+// checkProperties checks the invariants of message and that it stays valid once normalised, the way
+// the worker normalises every message before validating it.
+func checkProperties(t *testing.T, message proto.Message) bool {
+	t.Helper()
+
+	validation := Validate(message)
+	checkInvariants(t, validation)
+
+	normalized := proto.Clone(message)
+	Normalize(normalized)
+	checkIdempotent(t, normalized)
+
+	if validation.Valid && !Validate(normalized).Valid {
+		t.Errorf("Valid message should stay valid after normalization, but %v does not: %v", normalized, Validate(normalized).Errors)
+	}
+
+	return !t.Failed()
+}
+
+func checkQuick(t *testing.T, property any) {
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateKwekProperties(t *testing.T) {
+	checkQuick(t, func(guid, text, userId fragmentString, keep uint8) bool {
+		createKwek := &kwek.CreateKwek{
+			KwekGuid: pick(keep, 0, guid, "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc"),
+			Text:     pick(keep, 1, text, "Hello world!"),
+			UserId:   pick(keep, 2, userId, "123"),
+			PostedAt: validCreateUser().GetCreatedAt(),
+		}
+
+		return checkProperties(t, createKwek)
+	})
+}
+
+func TestUpdateKwekProperties(t *testing.T) {
+	checkQuick(t, func(guid, text fragmentString, keep uint8) bool {
+		updateKwek := &kwek.UpdateKwek{
+			KwekGuid:  pick(keep, 0, guid, "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc"),
+			Text:      pick(keep, 1, text, "Hello world!"),
+			UpdatedAt: validCreateUser().GetCreatedAt(),
+		}
+
+		return checkProperties(t, updateKwek)
+	})
+}
+
+func TestDeleteKwekProperties(t *testing.T) {
+	checkQuick(t, func(guid fragmentString, keep uint8) bool {
+		return checkProperties(t, &kwek.DeleteKwek{KwekGuid: pick(keep, 0, guid, "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc")})
+	})
+}
+
+func TestCreateUserProperties(t *testing.T) {
+	checkQuick(t, func(userId, username, email, displayName, avatarUrl fragmentString, keep uint8) bool {
+		valid := validCreateUser()
+		createUser := validCreateUser()
+		createUser.UserId = pick(keep, 0, userId, valid.GetUserId())
+		createUser.Username = pick(keep, 1, username, valid.GetUsername())
+		createUser.Email = pick(keep, 2, email, valid.GetEmail())
+		createUser.DisplayName = pick(keep, 3, displayName, valid.GetDisplayName())
+		createUser.AvatarUrl = pick(keep, 4, avatarUrl, valid.GetAvatarUrl())
+
+		return checkProperties(t, createUser)
+	})
+}
+
+func TestUpdateUserProperties(t *testing.T) {
+	checkQuick(t, func(userId, username, email, displayName, avatarUrl fragmentString, keep uint8) bool {
+		valid := validCreateUser()
+		updatedUsername := pick(keep, 1, username, valid.GetUsername())
+		updatedEmail := pick(keep, 2, email, valid.GetEmail())
+		updatedDisplayName := pick(keep, 3, displayName, valid.GetDisplayName())
+		updatedAvatarUrl := pick(keep, 4, avatarUrl, valid.GetAvatarUrl())
+
+		updateUser := &user.UpdateUser{
+			UserId:      pick(keep, 0, userId, valid.GetUserId()),
+			Username:    &updatedUsername,
+			Email:       &updatedEmail,
+			DisplayName: &updatedDisplayName,
+			AvatarUrl:   &updatedAvatarUrl,
+			UpdatedAt:   valid.GetCreatedAt(),
+		}
+
+		return checkProperties(t, updateUser)
+	})
+}
+
+func TestDeleteUserProperties(t *testing.T) {
+	checkQuick(t, func(userId fragmentString, keep uint8) bool {
+		return checkProperties(t, &user.DeleteUser{UserId: pick(keep, 0, userId, "553bb4d0-332e-401f-8e9d-e44b44aa0532")})
+	})
+}
@@ -0,0 +1,222 @@
+package validation
+
+import (
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net"
+	"net/mail"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// fieldValue is a field of the message being validated, together with the label errors are reported under.
+type fieldValue struct {
+	label   string
+	value   protoreflect.Value
+	present bool
+}
+
+// Rule is a single check of a field, declared for that field in the rule table. The rules of a field
+// run in order, and a rule that finds the value missing or malformed skips the rules after it.
+type Rule struct {
+	// check reports the problems of field to v, and returns false when the remaining rules should not run.
+	check func(field fieldValue, policy Policy, v *Validation) bool
+	// normalize, when set, rewrites the value of a string field into its canonical form. It is applied by Normalize.
+	normalize func(value string) string
+}
+
+// required rejects fields that are unset or empty.
+func required() Rule {
+	return Rule{check: func(field fieldValue, _ Policy, v *Validation) bool {
+		if !field.present {
+			v.addError(field.label, CodeRequired, nil)
+			return false
+		}
+
+		return assertNotEmpty(field.value.String(), field.label, v)
+	}}
+}
+
+// characters rejects text that is not valid UTF-8 or contains control characters.
+// Line breaks and tabs are only accepted when allowLineBreaks is set.
+func characters(allowLineBreaks bool) Rule {
+	return Rule{check: func(field fieldValue, _ Policy, v *Validation) bool {
+		return validateCharacters(field.value.String(), field.label, allowLineBreaks, v)
+	}}
+}
+
+// graphemeLength limits the number of user-perceived characters in text. Either bound may be nil.
+func graphemeLength(min, max func(Policy) int) Rule {
+	return Rule{check: func(field fieldValue, policy Policy, v *Validation) bool {
+		count := length(field.value.String())
+
+		if min != nil && count < min(policy) {
+			v.addError(field.label, CodeTooShort, map[string]any{"min": min(policy)})
+		} else if max != nil && count > max(policy) {
+			v.addError(field.label, CodeTooLong, map[string]any{"max": max(policy)})
+		}
+
+		return true
+	}}
+}
+
+// byteLength limits the size of a value in bytes, for values such as URLs that are not meant to be read.
+func byteLength(max func(Policy) int) Rule {
+	return Rule{check: func(field fieldValue, policy Policy, v *Validation) bool {
+		if len(field.value.String()) > max(policy) {
+			v.addError(field.label, CodeTooLong, map[string]any{"max": max(policy)})
+		}
+
+		return true
+	}}
+}
+
+// guid accepts GUIDs of the versions allowed by the policy, in any notation uuid.Parse understands.
+// Normalize rewrites them in canonical lowercase hyphenated form.
+func guid() Rule {
+	return Rule{
+		check: func(field fieldValue, policy Policy, v *Validation) bool {
+			parsed, err := uuid.Parse(field.value.String())
+
+			if err != nil || parsed == uuid.Nil {
+				v.addError(field.label, CodeInvalidFormat, map[string]any{"format": "GUID"})
+				return false
+			}
+
+			if len(policy.GuidVersions) == 0 {
+				return true
+			}
+
+			for _, version := range policy.GuidVersions {
+				if int(parsed.Version()) == version {
+					return true
+				}
+			}
+
+			v.addError(field.label, CodeUnsupportedVersion, map[string]any{"versions": policy.GuidVersions})
+			return false
+		},
+		normalize: canonicalGuid,
+	}
+}
+
+// email accepts anything net/mail can parse as a single address.
+func email() Rule {
+	return Rule{check: func(field fieldValue, _ Policy, v *Validation) bool {
+		if _, err := mail.ParseAddress(field.value.String()); err != nil {
+			v.addError(field.label, CodeInvalidFormat, map[string]any{"format": "email address"})
+			return false
+		}
+
+		return true
+	}}
+}
+
+// notReserved rejects usernames that share their canonical form with a reserved username.
+func notReserved(reserved func(Policy) []string) Rule {
+	return Rule{check: func(field fieldValue, policy Policy, v *Validation) bool {
+		canonical := CanonicalUsername(field.value.String())
+
+		for _, username := range reserved(policy) {
+			if canonical == CanonicalUsername(username) {
+				v.addError(field.label, CodeReserved, nil)
+				return false
+			}
+		}
+
+		return true
+	}}
+}
+
+// publicUrl accepts absolute https URLs without credentials or a non-default port, that point to a
+// public host. When allowedHosts returns any hosts, the URL must point to one of them.
+func publicUrl(allowedHosts func(Policy) []string) Rule {
+	return Rule{check: func(field fieldValue, policy Policy, v *Validation) bool {
+		parsed, err := neturl.Parse(field.value.String())
+
+		if err != nil || !parsed.IsAbs() {
+			v.addError(field.label, CodeInvalidFormat, map[string]any{"format": "URL"})
+			return false
+		}
+
+		if parsed.Scheme != "https" {
+			v.addError(field.label, CodeInvalidScheme, map[string]any{"scheme": "https"})
+			return false
+		}
+
+		if parsed.User != nil {
+			v.addError(field.label, CodeHasCredentials, nil)
+		}
+
+		if port := parsed.Port(); port != "" && port != "443" {
+			v.addError(field.label, CodeInvalidPort, map[string]any{"port": port})
+		}
+
+		host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+
+		if host == "" {
+			v.addError(field.label, CodeInvalidFormat, map[string]any{"format": "URL"})
+			return false
+		}
+
+		if !isAllowedHost(host, allowedHosts(policy)) {
+			v.addError(field.label, CodeHostNotAllowed, map[string]any{"host": host})
+		}
+
+		return true
+	}}
+}
+
+// isAllowedHost reports whether host may be linked to. IP literals and names that only resolve on
+// internal networks are never allowed; other hosts must match allowed, unless it is empty.
+func isAllowedHost(host string, allowed []string) bool {
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return false
+	}
+
+	for _, suffix := range []string{".local", ".localhost", ".internal", ".lan", ".home.arpa"} {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, entry := range allowed {
+		entry = strings.ToLower(entry)
+
+		if host == strings.TrimPrefix(entry, ".") || strings.HasPrefix(entry, ".") && strings.HasSuffix(host, entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// timestamp accepts times within the window of the policy: no further in the future than the allowed
+// skew and no older than the maximum age.
+func timestamp() Rule {
+	return Rule{check: func(field fieldValue, policy Policy, v *Validation) bool {
+		timestamp, ok := field.value.Message().Interface().(*timestamppb.Timestamp)
+
+		if !ok || !timestamp.IsValid() {
+			v.addError(field.label, CodeInvalidFormat, map[string]any{"format": "timestamp"})
+			return false
+		}
+
+		now := policy.now()
+
+		// Publishers' clocks may run slightly ahead of ours, so timestamps are allowed to be a little in the future.
+		if timestamp.AsTime().After(now.Add(policy.TimestampFutureSkew)) {
+			v.addError(field.label, CodeInFuture, map[string]any{"allowedSkew": policy.TimestampFutureSkew.String()})
+		} else if timestamp.AsTime().Before(now.Add(-24 * time.Hour * time.Duration(policy.TimestampMaxAgeDays))) {
+			v.addError(field.label, CodeTooOld, map[string]any{"maxAgeDays": policy.TimestampMaxAgeDays})
+		}
+
+		return true
+	}}
+}
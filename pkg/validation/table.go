@@ -0,0 +1,107 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+var (
+	// ruleTable holds the rules of every validated field, keyed by the full name of the field.
+	ruleTable = map[protoreflect.FullName][]Rule{}
+	// validatedMessages holds the full names of the message types that have rules. Validate rejects all others.
+	validatedMessages = map[protoreflect.FullName]bool{}
+)
+
+func init() {
+	declareRules(&kwek.CreateKwek{}, map[string][]Rule{
+		"kwekGuid": {required(), guid()},
+		"text":     kwekTextRules(),
+		"userId":   {required()},
+		"postedAt": {required(), timestamp()},
+	})
+
+	declareRules(&kwek.UpdateKwek{}, map[string][]Rule{
+		"kwekGuid":  {required(), guid()},
+		"text":      kwekTextRules(),
+		"updatedAt": {required(), timestamp()},
+	})
+
+	declareRules(&kwek.DeleteKwek{}, map[string][]Rule{
+		"kwekGuid": {required(), guid()},
+	})
+
+	declareRules(&user.CreateUser{}, userRules("createdAt"))
+	declareRules(&user.UpdateUser{}, userRules("updatedAt"))
+
+	declareRules(&user.DeleteUser{}, map[string][]Rule{
+		"userId": {required()},
+	})
+}
+
+func kwekTextRules() []Rule {
+	return []Rule{
+		required(),
+		characters(true),
+		graphemeLength(nil, func(p Policy) int { return p.TextMaxLength }),
+	}
+}
+
+// userRules returns the rules shared by the user messages. Optional fields of UpdateUser are only
+// checked when they are set, see Validate.
+func userRules(timestampField string) map[string][]Rule {
+	return map[string][]Rule{
+		"userId": {required()},
+		"username": {
+			required(),
+			characters(false),
+			graphemeLength(
+				func(p Policy) int { return p.UsernameMinLength },
+				func(p Policy) int { return p.UsernameMaxLength },
+			),
+			notReserved(func(p Policy) []string { return p.ReservedUsernames }),
+		},
+		"email": {required(), characters(false), email()},
+		"displayName": {
+			required(),
+			characters(false),
+			graphemeLength(nil, func(p Policy) int { return p.DisplayNameMaxLength }),
+		},
+		"avatarUrl": {
+			required(),
+			byteLength(func(p Policy) int { return p.AvatarUrlMaxLength }),
+			publicUrl(func(p Policy) []string { return p.AvatarHosts }),
+		},
+		timestampField: {required(), timestamp()},
+	}
+}
+
+// declareRules adds the rules of a message type to the rule table. Fields are named by their JSON
+// name, which protoc derives the same way however the field is spelled in the .proto file, and the
+// same name in PascalCase is the label errors are reported under. It panics on an unknown field,
+// so that a renamed field is caught as soon as the package is loaded.
+func declareRules(message proto.Message, fields map[string][]Rule) {
+	descriptor := message.ProtoReflect().Descriptor()
+
+	for name, rules := range fields {
+		field := descriptor.Fields().ByJSONName(name)
+
+		if field == nil {
+			panic(fmt.Sprintf("validation: %s has no field with JSON name %q", descriptor.FullName(), name))
+		}
+
+		ruleTable[field.FullName()] = rules
+	}
+
+	validatedMessages[descriptor.FullName()] = true
+}
+
+// label returns the name a field is reported under in validation errors, e.g. "KwekGuid".
+func label(field protoreflect.FieldDescriptor) string {
+	name := field.JSONName()
+
+	return strings.ToUpper(name[:1]) + name[1:]
+}
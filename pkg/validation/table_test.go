@@ -0,0 +1,66 @@
+package validation
+
+import (
+	"github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+)
+
+func TestDeclareRulesPanicsOnUnknownField(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Declaring rules for an unknown field should panic, but does not")
+		}
+	}()
+
+	declareRules(&kwek.DeleteKwek{}, map[string][]Rule{"kwekId": {required()}})
+}
+
+func TestErrorsAreLabelledWithPascalCaseFieldNames(t *testing.T) {
+	validation := Validate(&user.CreateUser{})
+	expected := []string{"UserId", "Username", "Email", "DisplayName", "AvatarUrl", "CreatedAt"}
+
+	if len(validation.Errors) != len(expected) {
+		t.Fatalf("Validation should have %d errors, but has %v", len(expected), validation.Errors)
+	}
+
+	for _, field := range expected {
+		found := false
+
+		for _, err := range validation.Errors {
+			found = found || err.Field == field && err.Code == CodeRequired
+		}
+
+		if !found {
+			t.Errorf("Validation should report %s as required, but does not: %v", field, validation.Errors)
+		}
+	}
+}
+
+func TestValidateUpdateUserSkipsEmptyOptionalFields(t *testing.T) {
+	empty := ""
+	updateUser := &user.UpdateUser{
+		UserId:      "553bb4d0-332e-401f-8e9d-e44b44aa0532",
+		Username:    &empty,
+		DisplayName: &empty,
+		UpdatedAt:   timestamppb.Now(),
+	}
+
+	if validation := ValidateUpdateUser(updateUser); !validation.Valid {
+		t.Errorf("Validation should be valid, but is not: %v", validation.Errors)
+	}
+}
+
+func TestValidateCreateKwekWithInvalidTimestamp(t *testing.T) {
+	validation := ValidateCreateKwek(&kwek.CreateKwek{
+		KwekGuid: "f9d30d37-63a8-44a9-b2c3-3a45eb0701bc",
+		Text:     "Hello world!",
+		UserId:   "123",
+		PostedAt: &timestamppb.Timestamp{Seconds: pinnedNow.Unix(), Nanos: -1},
+	})
+
+	if len(validation.Errors) != 1 || validation.Errors[0].Code != CodeInvalidFormat {
+		t.Errorf("Validation should have one %s error, but has %v", CodeInvalidFormat, validation.Errors)
+	}
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/protobuf/proto"
+	"kwekker-worker/pkg/rabbitmq"
+)
+
+// fieldChange records the value of a single column before and after a mutation.
+// Before is nil for created entities and After is nil for deleted ones.
+type fieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// writeAuditLog appends an entry to the audit log. It must be called with the same transaction
+// that applied the mutation, so that the entry is only persisted when the change itself is.
+func (w *Worker) writeAuditLog(
+	ctx context.Context,
+	tx pgx.Tx,
+	message rabbitmq.Message,
+	entityId string,
+	changes map[string]fieldChange,
+) error {
+	encodedChanges, err := json.Marshal(changes)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO "AuditLog" ("MessageType", "RoutingKey", "MessageId", "EntityId", "Changes")
+			 VALUES ($1, $2, NULLIF($3, ''), $4, $5)`,
+		string(proto.MessageName(message.Protobuf)),
+		message.RoutingKey,
+		message.MessageId,
+		entityId,
+		encodedChanges,
+	)
+
+	return err
+}
@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"github.com/google/uuid"
+	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	userproto "github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"kwekker-worker/pkg/config"
+	"testing"
+)
+
+func createTestUser(t *testing.T, w *Worker, userId string, username string) {
+	t.Helper()
+
+	handle(t, w, testMessage("user.create", &userproto.CreateUser{
+		UserId:      userId,
+		Username:    username,
+		Email:       username + "@example.com",
+		DisplayName: username,
+		CreatedAt:   timestamppb.Now(),
+	}))
+}
+
+func TestAuditEntryIsWrittenWithTheChange(t *testing.T) {
+	w := connectTestWorker(t, config.Config{})
+	ctx := context.Background()
+
+	// Handlers run in a transaction of the caller, as in a dry run that executes SQL.
+	outer, err := w.dbconn.Begin(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+
+	w.db = outer
+	createTestUser(t, w, "provider|1", "kwekker_fan")
+
+	var count int
+
+	if err = outer.QueryRow(ctx, `SELECT count(*) FROM "AuditLog" WHERE "EntityId" = 'provider|1'`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count audit entries: %v", err)
+	}
+
+	if count != 1 {
+		t.Errorf("Created user should have an audit entry in its transaction, but has %d", count)
+	}
+
+	if err = outer.Rollback(ctx); err != nil {
+		t.Fatalf("Failed to roll back transaction: %v", err)
+	}
+
+	if count := countRows(t, w, "AuditLog", `"EntityId" = 'provider|1'`); count != 0 {
+		t.Errorf("Audit entry should be rolled back with the user, but %d are kept", count)
+	}
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	w := connectTestWorker(t, config.Config{})
+	ctx := context.Background()
+
+	createTestUser(t, w, "provider|1", "kwekker_fan")
+
+	for _, statement := range []string{
+		`UPDATE "AuditLog" SET "EntityId" = 'provider|2'`,
+		`DELETE FROM "AuditLog"`,
+		`TRUNCATE "AuditLog"`,
+	} {
+		if _, err := w.dbconn.Exec(ctx, statement); err == nil {
+			t.Errorf("%s should be rejected, but is not", statement)
+		}
+	}
+
+	if count := countRows(t, w, "AuditLog", `"EntityId" = 'provider|1'`); count != 1 {
+		t.Errorf("Audit entry should be kept, but there are %d", count)
+	}
+}
+
+func TestDeletingUserAuditsTheirKweks(t *testing.T) {
+	w := connectTestWorker(t, config.Config{})
+
+	createTestUser(t, w, "provider|1", "kwekker_fan")
+
+	kwekGuid := uuid.NewString()
+
+	handle(t, w, testMessage("kwek.create", &kwekproto.CreateKwek{
+		KwekGuid: kwekGuid,
+		Text:     "Hello",
+		UserId:   "provider|1",
+		PostedAt: timestamppb.Now(),
+	}))
+
+	deleteUser := testMessage("user.delete", &userproto.DeleteUser{UserId: "provider|1"})
+	handle(t, w, deleteUser)
+
+	if count := countRows(t, w, "Kweks", `"Guid" = $1`, kwekGuid); count != 0 {
+		t.Errorf("Kwek should be deleted with its user, but is not")
+	}
+
+	if count := countRows(t, w, "AuditLog", `"EntityId" = $1 AND "MessageId" = $2`, kwekGuid, deleteUser.MessageId); count != 1 {
+		t.Errorf("Kwek deleted with its user should have an audit entry of the deletion, but has %d", count)
+	}
+}
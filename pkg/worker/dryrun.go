@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"go.uber.org/zap"
+	"kwekker-worker/pkg/metrics"
+	"kwekker-worker/pkg/rabbitmq"
+	"time"
+)
+
+// handleDryRun counts the outcome a valid message would have had and releases it. When configured to,
+// the handler runs inside a transaction that is rolled back afterwards, so that outcomes decided by the
+// database, such as conflicts and rate limits, are reported as well.
+func (w *Worker) handleDryRun(message rabbitmq.Message) {
+	logger := w.messageLogger(message)
+	outcome := metrics.OutcomeAccepted
+
+	if w.config.DryRun.ExecuteSql {
+		if err := w.handleInRolledBackTransaction(message); err != nil {
+			outcome = rejectionReason(err)
+			logger.Info("Message would be dead-lettered", zap.String("reason", outcome), zap.Error(err))
+		}
+	}
+
+	metrics.RecordDryRunOutcome(message.Queue, outcome)
+
+	if err := message.Release(); err != nil {
+		logger.Error("Failed to release dry-run message", zap.Error(err))
+	}
+}
+
+func (w *Worker) handleInRolledBackTransaction(message rabbitmq.Message) error {
+	logger := w.messageLogger(message)
+	ctx := context.Background()
+	tx, err := w.dbconn.Begin(ctx)
+
+	if err != nil {
+		logger.Error("Failed to begin dry-run transaction", zap.Error(err))
+		return err
+	}
+
+	defer func() {
+		w.db = w.dbconn
+
+		if err := tx.Rollback(ctx); err != nil {
+			logger.Error("Failed to roll back dry-run transaction", zap.Error(err))
+		}
+	}()
+
+	// The handlers begin their own transactions on w.db, which become savepoints of this one.
+	w.db = tx
+
+	return w.handleMessage(message)
+}
+
+// reportDryRun logs the outcomes counted so far for every queue, once per interval, until done is closed. An
+// interval of zero or less disables the log.
+func (w *Worker) reportDryRun(interval time.Duration, done <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			metrics.DryRunOutcomes.Do(func(queue expvar.KeyValue) {
+				w.logger.Info("Dry-run outcomes", zap.String("queue", queue.Key), zap.String("outcomes", queue.Value.String()))
+			})
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"go.uber.org/zap"
+	"kwekker-worker/pkg/config"
+	"testing"
+	"time"
+)
+
+func TestReportDryRunStops(t *testing.T) {
+	w := NewWorker(zap.NewNop().Sugar(), config.Config{})
+
+	for _, interval := range []time.Duration{0, -time.Second, time.Millisecond} {
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+
+		go func() {
+			w.reportDryRun(interval, done)
+			close(stopped)
+		}()
+
+		if interval > 0 {
+			time.Sleep(5 * interval)
+			close(done)
+		}
+
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Errorf("Report with interval %s should stop, but keeps running", interval)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"kwekker-worker/pkg/entities"
+	"kwekker-worker/pkg/validation"
+)
+
+// syncKwekEntities replaces the hashtags and mentions stored for a kwek with the ones found in its text.
+// Mentions of usernames that do not exist are ignored.
+func (w *Worker) syncKwekEntities(ctx context.Context, tx pgx.Tx, kwekId int32, text string) error {
+	extracted := entities.Extract(text)
+
+	_, err := tx.Exec(ctx, `DELETE FROM "KwekHashtags" WHERE "KwekId" = $1`, kwekId)
+
+	if err != nil {
+		return err
+	}
+
+	if len(extracted.Hashtags) > 0 {
+		_, err = tx.Exec(
+			ctx,
+			`INSERT INTO "KwekHashtags" ("KwekId", "Hashtag") SELECT $1, unnest($2::text[])`,
+			kwekId,
+			extracted.Hashtags,
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM "KwekMentions" WHERE "KwekId" = $1`, kwekId)
+
+	if err != nil {
+		return err
+	}
+
+	if len(extracted.Mentions) > 0 {
+		canonicalMentions := make([]string, len(extracted.Mentions))
+
+		for i, mention := range extracted.Mentions {
+			canonicalMentions[i] = validation.CanonicalUsername(mention)
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`INSERT INTO "KwekMentions" ("KwekId", "UserId")
+				 SELECT DISTINCT $1::integer, "Id" FROM "Users" WHERE "UsernameCanonical" = ANY($2::text[])`,
+			kwekId,
+			canonicalMentions,
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
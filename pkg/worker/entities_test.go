@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"github.com/google/uuid"
+	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"kwekker-worker/pkg/config"
+	"testing"
+)
+
+func TestUpdatingKwekReplacesItsHashtagsAndMentions(t *testing.T) {
+	w := connectTestWorker(t, config.Config{})
+
+	createTestUser(t, w, "provider|1", "alice")
+	createTestUser(t, w, "provider|2", "bob")
+
+	kwekGuid := uuid.NewString()
+
+	handle(t, w, testMessage("kwek.create", &kwekproto.CreateKwek{
+		KwekGuid: kwekGuid,
+		Text:     "Learning #golang with @alice and @nobody",
+		UserId:   "provider|1",
+		PostedAt: timestamppb.Now(),
+	}))
+
+	kwek := `"KwekId" = (SELECT "Id" FROM "Kweks" WHERE "Guid" = $1)`
+	mentions := kwek + ` AND "UserId" = (SELECT "Id" FROM "Users" WHERE "ProviderId" = $2)`
+
+	if countRows(t, w, "KwekHashtags", kwek+` AND "Hashtag" = 'golang'`, kwekGuid) != 1 {
+		t.Errorf("Created kwek should have hashtag golang, but does not")
+	}
+
+	if count := countRows(t, w, "KwekMentions", kwek, kwekGuid); count != 1 || countRows(t, w, "KwekMentions", mentions, kwekGuid, "provider|1") != 1 {
+		t.Errorf("Created kwek should only mention alice, as nobody does not exist, but has %d mentions", count)
+	}
+
+	handle(t, w, testMessage("kwek.update", &kwekproto.UpdateKwek{
+		KwekGuid:  kwekGuid,
+		Text:      "Switched to #rust, thanks @bob",
+		UpdatedAt: timestamppb.Now(),
+	}))
+
+	if countRows(t, w, "KwekHashtags", kwek, kwekGuid) != 1 || countRows(t, w, "KwekHashtags", kwek+` AND "Hashtag" = 'rust'`, kwekGuid) != 1 {
+		t.Errorf("Updated kwek should only have hashtag rust, but does not")
+	}
+
+	if countRows(t, w, "KwekMentions", kwek, kwekGuid) != 1 || countRows(t, w, "KwekMentions", mentions, kwekGuid, "provider|2") != 1 {
+		t.Errorf("Updated kwek should only mention bob, but does not")
+	}
+}
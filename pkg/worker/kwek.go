@@ -2,62 +2,318 @@ package worker
 
 import (
 	"context"
+	"errors"
 	kwekkerprotobufs "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
+	"kwekker-worker/pkg/metrics"
+	"kwekker-worker/pkg/moderation"
+	"kwekker-worker/pkg/rabbitmq"
+	"time"
 )
 
-func (w *Worker) handleCreateKwek(createKwek *kwekkerprotobufs.CreateKwek) {
-	w.logger.Debug("Handling create kwek request", "kwek", createKwek)
+func (w *Worker) handleCreateKwek(message rabbitmq.Message, createKwek *kwekkerprotobufs.CreateKwek) error {
+	logger := w.messageLogger(message)
+	logger.Debug("Handling create kwek request", zap.Stringer("kwek", createKwek))
 
-	_, err := w.dbconn.Exec(
-		context.Background(),
-		`INSERT INTO "Kweks" ("Guid", "UserId", "Text", "PostedAt")
-			 VALUES ($1, (SELECT "Id" FROM "Users" WHERE "ProviderId" = $2), $3, $4)`,
+	ctx := context.Background()
+	outcome := w.moderator.Moderate(createKwek.GetText())
+
+	tx, err := w.db.Begin(ctx)
+
+	if err != nil {
+		logger.Error("Failed to begin transaction", zap.Error(err))
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	// The token is taken in the transaction of the kwek, so that it is given back when a failed attempt is retried.
+	allowed, err := w.rateLimiter.Allow(ctx, tx, "kwek:"+createKwek.GetUserId())
+
+	if err != nil {
+		logger.Error("Failed to check kwek rate limit", zap.Error(err))
+		return err
+	}
+
+	if !allowed {
+		metrics.KweksRateLimited.Add(1)
+		logger.Info("User exceeded the kwek rate limit", zap.String("userId", createKwek.GetUserId()))
+		return errRateLimited
+	}
+
+	if err = w.recordModeration(ctx, tx, createKwek.GetKwekGuid(), outcome); err != nil {
+		logger.Error("Failed to record moderation results of kwek", zap.Error(err))
+		return err
+	}
+
+	if outcome.Verdict == moderation.Reject {
+		if err = tx.Commit(ctx); err != nil {
+			logger.Error("Failed to commit moderation results of kwek", zap.Error(err))
+			return err
+		}
+
+		logger.Info("Kwek was rejected by moderation", zap.Any("results", outcome.Results))
+		return nil
+	}
+
+	var kwekId int32
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO "Kweks" ("Guid", "UserId", "Text", "PostedAt", "SearchLanguage", "SearchVector", "ModerationStatus")
+			 VALUES ($1, (SELECT "Id" FROM "Users" WHERE "ProviderId" = $2), $3, $4, $5::regconfig, to_tsvector($5::regconfig, $3), $6)
+			 RETURNING "Id"`,
 		createKwek.GetKwekGuid(),
 		createKwek.GetUserId(),
 		createKwek.GetText(),
 		createKwek.GetPostedAt().AsTime(),
-	)
+		w.config.Search.Language,
+		outcome.Verdict.String(),
+	).Scan(&kwekId)
+
+	if err != nil {
+		logger.Error("Failed to insert kwek into database", zap.Error(err))
+		return err
+	}
+
+	if err = w.syncKwekEntities(ctx, tx, kwekId, createKwek.GetText()); err != nil {
+		logger.Error("Failed to store hashtags and mentions of kwek", zap.Error(err))
+		return err
+	}
+
+	err = w.writeAuditLog(ctx, tx, message, createKwek.GetKwekGuid(), map[string]fieldChange{
+		"UserId":           {After: createKwek.GetUserId()},
+		"Text":             {After: createKwek.GetText()},
+		"PostedAt":         {After: createKwek.GetPostedAt().AsTime()},
+		"ModerationStatus": {After: outcome.Verdict.String()},
+	})
 
 	if err != nil {
-		w.logger.Error("Failed to insert kwek into database", zap.Error(err))
-		return
+		logger.Error("Failed to write audit log for kwek creation", zap.Error(err))
+		return err
 	}
 
-	w.logger.Debug("Successfully inserted kwek into database")
+	w.recordLatency(ctx, tx, message)
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Error("Failed to commit kwek insertion", zap.Error(err))
+		return err
+	}
+
+	logger.Debug("Successfully inserted kwek into database")
+
+	return nil
 }
 
-func (w *Worker) handleUpdateKwek(updateKwek *kwekkerprotobufs.UpdateKwek) {
-	w.logger.Debug("Handling update kwek request", "kwek", updateKwek)
+func (w *Worker) handleUpdateKwek(message rabbitmq.Message, updateKwek *kwekkerprotobufs.UpdateKwek) error {
+	logger := w.messageLogger(message)
+	logger.Debug("Handling update kwek request", zap.Stringer("kwek", updateKwek))
 
-	_, err := w.dbconn.Exec(
-		context.Background(),
-		`UPDATE "Kweks" SET "Text" = $1 WHERE "Guid" = $2`,
-		updateKwek.GetText(),
+	outcome := w.moderator.Moderate(updateKwek.GetText())
+
+	ctx := context.Background()
+	tx, err := w.db.Begin(ctx)
+
+	if err != nil {
+		logger.Error("Failed to begin transaction", zap.Error(err))
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	var (
+		kwekId                   int32
+		previousText             string
+		previousModerationStatus string
+	)
+
+	err = tx.QueryRow(
+		ctx,
+		`SELECT "Id", "Text", "ModerationStatus" FROM "Kweks" WHERE "Guid" = $1 FOR UPDATE`,
 		updateKwek.GetKwekGuid(),
+	).Scan(&kwekId, &previousText, &previousModerationStatus)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		logger.Debug("No kwek to update")
+		return nil
+	}
+
+	if err != nil {
+		logger.Error("Failed to read kwek from database", zap.Error(err))
+		return err
+	}
+
+	if err = w.recordModeration(ctx, tx, updateKwek.GetKwekGuid(), outcome); err != nil {
+		logger.Error("Failed to record moderation results of kwek", zap.Error(err))
+		return err
+	}
+
+	if outcome.Verdict == moderation.Reject {
+		if err = tx.Commit(ctx); err != nil {
+			logger.Error("Failed to commit moderation results of kwek", zap.Error(err))
+			return err
+		}
+
+		logger.Info("Kwek update was rejected by moderation", zap.Any("results", outcome.Results))
+		return nil
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE "Kweks"
+			 SET "Text" = $1, "SearchLanguage" = $3::regconfig, "SearchVector" = to_tsvector($3::regconfig, $1),
+				 "ModerationStatus" = $4
+			 WHERE "Id" = $2`,
+		updateKwek.GetText(),
+		kwekId,
+		w.config.Search.Language,
+		outcome.Verdict.String(),
 	)
 
 	if err != nil {
-		w.logger.Error("Failed to update kwek in database", zap.Error(err))
-		return
+		logger.Error("Failed to update kwek in database", zap.Error(err))
+		return err
 	}
 
-	w.logger.Debug("Successfully updated kwek in database")
+	if err = w.syncKwekEntities(ctx, tx, kwekId, updateKwek.GetText()); err != nil {
+		logger.Error("Failed to store hashtags and mentions of kwek", zap.Error(err))
+		return err
+	}
+
+	changes := map[string]fieldChange{
+		"Text": {Before: previousText, After: updateKwek.GetText()},
+	}
+
+	if previousModerationStatus != outcome.Verdict.String() {
+		changes["ModerationStatus"] = fieldChange{Before: previousModerationStatus, After: outcome.Verdict.String()}
+	}
+
+	err = w.writeAuditLog(ctx, tx, message, updateKwek.GetKwekGuid(), changes)
+
+	if err != nil {
+		logger.Error("Failed to write audit log for kwek update", zap.Error(err))
+		return err
+	}
+
+	w.recordLatency(ctx, tx, message)
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Error("Failed to commit kwek update", zap.Error(err))
+		return err
+	}
+
+	logger.Debug("Successfully updated kwek in database")
+
+	return nil
 }
 
-func (w *Worker) handleDeleteKwek(deleteKwek *kwekkerprotobufs.DeleteKwek) {
-	w.logger.Debug("Handling delete kwek request", "kwek", deleteKwek)
+func (w *Worker) handleDeleteKwek(message rabbitmq.Message, deleteKwek *kwekkerprotobufs.DeleteKwek) error {
+	logger := w.messageLogger(message)
+	logger.Debug("Handling delete kwek request", zap.Stringer("kwek", deleteKwek))
+
+	ctx := context.Background()
+	tx, err := w.db.Begin(ctx)
+
+	if err != nil {
+		logger.Error("Failed to begin transaction", zap.Error(err))
+		return err
+	}
+
+	defer tx.Rollback(ctx)
 
-	_, err := w.dbconn.Exec(
-		context.Background(),
-		`DELETE FROM "Kweks" WHERE "Guid" = $1`,
+	var (
+		previousText     string
+		previousPostedAt time.Time
+	)
+
+	err = tx.QueryRow(
+		ctx,
+		`DELETE FROM "Kweks" WHERE "Guid" = $1 RETURNING "Text", "PostedAt"`,
 		deleteKwek.GetKwekGuid(),
+	).Scan(&previousText, &previousPostedAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		logger.Debug("No kwek to delete")
+		return nil
+	}
+
+	if err != nil {
+		logger.Error("Failed to delete kwek in database", zap.Error(err))
+		return err
+	}
+
+	err = w.writeAuditLog(ctx, tx, message, deleteKwek.GetKwekGuid(), map[string]fieldChange{
+		"Text":     {Before: previousText},
+		"PostedAt": {Before: previousPostedAt},
+	})
+
+	if err != nil {
+		logger.Error("Failed to write audit log for kwek deletion", zap.Error(err))
+		return err
+	}
+
+	w.recordLatency(ctx, tx, message)
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Error("Failed to commit kwek deletion", zap.Error(err))
+		return err
+	}
+
+	logger.Debug("Successfully deleted kwek in database")
+
+	return nil
+}
+
+// deleteKweksOfUser deletes the kweks of the user with the given provider id, and writes an audit log entry for
+// each of them as deleted by message.
+func (w *Worker) deleteKweksOfUser(ctx context.Context, tx pgx.Tx, message rabbitmq.Message, userId string) error {
+	rows, err := tx.Query(
+		ctx,
+		`DELETE FROM "Kweks" WHERE "UserId" = (SELECT "Id" FROM "Users" WHERE "ProviderId" = $1)
+			 RETURNING "Guid"::text, "Text", "PostedAt"`,
+		userId,
 	)
 
 	if err != nil {
-		w.logger.Error("Failed to delete kwek in database", zap.Error(err))
-		return
+		return err
+	}
+
+	defer rows.Close()
+
+	type deletedKwek struct {
+		guid     string
+		text     string
+		postedAt time.Time
+	}
+
+	// The rows are read before writing the audit log, as the connection cannot run a query while reading another.
+	kweks := make([]deletedKwek, 0)
+
+	for rows.Next() {
+		var kwek deletedKwek
+
+		if err = rows.Scan(&kwek.guid, &kwek.text, &kwek.postedAt); err != nil {
+			return err
+		}
+
+		kweks = append(kweks, kwek)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, kwek := range kweks {
+		err = w.writeAuditLog(ctx, tx, message, kwek.guid, map[string]fieldChange{
+			"Text":     {Before: kwek.text},
+			"PostedAt": {Before: kwek.postedAt},
+		})
+
+		if err != nil {
+			return err
+		}
 	}
 
-	w.logger.Debug("Successfully deleted kwek in database")
+	return nil
 }
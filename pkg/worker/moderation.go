@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"kwekker-worker/pkg/config"
+	"kwekker-worker/pkg/moderation"
+)
+
+// AddModerationRule registers a custom moderation rule that runs alongside the configured ones.
+// It must be called before Initialize.
+func (w *Worker) AddModerationRule(rule moderation.Rule) {
+	w.moderationRules = append(w.moderationRules, rule)
+}
+
+func (w *Worker) initializeModerator() error {
+	moderator, err := w.newModerator(w.config.Moderation)
+
+	if err != nil {
+		return err
+	}
+
+	w.moderator = moderator
+
+	return nil
+}
+
+// newModerator creates a moderator with the configured rules and the custom ones.
+func (w *Worker) newModerator(config config.ModerationConfig) (*moderation.Moderator, error) {
+	moderator, err := moderation.NewModerator(config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range w.moderationRules {
+		moderator.AddRule(rule)
+	}
+
+	return moderator, nil
+}
+
+// recordModeration stores the verdict of every moderation rule that was run against a kwek.
+func (w *Worker) recordModeration(ctx context.Context, tx pgx.Tx, kwekGuid string, outcome moderation.Outcome) error {
+	for _, result := range outcome.Results {
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO "KwekModerationResults" ("KwekGuid", "Rule", "Verdict", "Reason")
+				 VALUES ($1, $2, $3, NULLIF($4, ''))`,
+			kwekGuid,
+			result.Rule,
+			result.Verdict.String(),
+			result.Reason,
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
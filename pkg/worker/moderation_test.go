@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"github.com/google/uuid"
+	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"kwekker-worker/pkg/config"
+	"testing"
+)
+
+func TestKweksAreModeratedWhenCreatedAndUpdated(t *testing.T) {
+	w := connectTestWorker(t, config.Config{
+		Moderation: config.ModerationConfig{
+			BlockedDomains: []string{"spam.example"},
+			MaxCapsRatio:   0.7,
+			MinCapsLetters: 10,
+		},
+	})
+
+	createTestUser(t, w, "provider|1", "alice")
+
+	flagged, rejected := uuid.NewString(), uuid.NewString()
+
+	for guid, text := range map[string]string{flagged: "THIS IS A VERY LOUD KWEK", rejected: "Win at https://spam.example"} {
+		handle(t, w, testMessage("kwek.create", &kwekproto.CreateKwek{
+			KwekGuid: guid,
+			Text:     text,
+			UserId:   "provider|1",
+			PostedAt: timestamppb.Now(),
+		}))
+	}
+
+	if countRows(t, w, "Kweks", `"Guid" = $1 AND "ModerationStatus" = 'flag'`, flagged) != 1 {
+		t.Errorf("Kwek with too many capitals should be stored as flagged, but is not")
+	}
+
+	if countRows(t, w, "Kweks", `"Guid" = $1`, rejected) != 0 {
+		t.Errorf("Kwek linking to a blocked domain should not be stored, but is")
+	}
+
+	if countRows(t, w, "KwekModerationResults", `"KwekGuid" = $1 AND "Verdict" = 'reject'`, rejected) != 1 {
+		t.Errorf("Rejection of kwek should be recorded, but is not")
+	}
+
+	handle(t, w, testMessage("kwek.update", &kwekproto.UpdateKwek{
+		KwekGuid:  flagged,
+		Text:      "Calmer now, see https://spam.example",
+		UpdatedAt: timestamppb.Now(),
+	}))
+
+	if countRows(t, w, "Kweks", `"Guid" = $1 AND "Text" = 'THIS IS A VERY LOUD KWEK'`, flagged) != 1 {
+		t.Errorf("Update linking to a blocked domain should be rejected, but the kwek was changed")
+	}
+
+	handle(t, w, testMessage("kwek.update", &kwekproto.UpdateKwek{
+		KwekGuid:  flagged,
+		Text:      "Calmer now",
+		UpdatedAt: timestamppb.Now(),
+	}))
+
+	if countRows(t, w, "Kweks", `"Guid" = $1 AND "Text" = 'Calmer now' AND "ModerationStatus" = 'allow'`, flagged) != 1 {
+		t.Errorf("Allowed update should be stored and clear the flag, but is not")
+	}
+}
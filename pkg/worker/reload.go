@@ -0,0 +1,184 @@
+package worker
+
+import (
+	"go.uber.org/zap"
+	"kwekker-worker/pkg/config"
+	"kwekker-worker/pkg/logging"
+	"kwekker-worker/pkg/validation"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"syscall"
+)
+
+// UseLogLevels lets reloads change levels, the levels of the logger the worker was created with.
+// It must be called before Initialize.
+func (w *Worker) UseLogLevels(levels *logging.Levels) {
+	w.logLevels = levels
+}
+
+// watchConfig reloads the configuration whenever its files change or the process receives SIGHUP, and
+// sends every valid configuration to reloads. Invalid configurations are logged and otherwise ignored.
+func (w *Worker) watchConfig(reloads chan<- config.Config) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	// Without a watcher both channels stay nil, and only SIGHUP triggers a reload.
+	var changes <-chan struct{}
+	var watchErrors <-chan error
+
+	if watcher, err := config.Watch(); err != nil {
+		w.logger.Error("Failed to watch the config files; send SIGHUP to reload them", zap.Error(err))
+	} else {
+		changes, watchErrors = watcher.Changes, watcher.Errors
+	}
+
+	for {
+		select {
+		case <-hangups:
+			w.logger.Info("Reloading configuration after SIGHUP")
+		case <-changes:
+			w.logger.Info("Reloading configuration after the config files changed")
+		case err := <-watchErrors:
+			w.logger.Error("Failed to watch the config files", zap.Error(err))
+			continue
+		}
+
+		conf, err := config.LoadConfig()
+
+		if err == nil {
+			err = conf.Validate()
+		}
+
+		if err != nil {
+			w.logger.Error("Keeping the current configuration, as the reloaded one is invalid", zap.Error(err))
+			continue
+		}
+
+		reloads <- *conf
+	}
+}
+
+// applyConfig applies the settings of a reloaded configuration that can change while messages are
+// being handled: the log levels, validation limits, rate limits, moderation rules, latency retention, and
+// whether each queue is consumed and retried. Changes to any other setting are logged and ignored until a restart.
+func (w *Worker) applyConfig(conf config.Config) {
+	for _, setting := range restartRequired(w.config, conf) {
+		w.logger.Warn("Ignoring changed setting, as it only takes effect after a restart", zap.String("setting", setting))
+	}
+
+	if w.logLevels != nil {
+		if err := w.logLevels.Set(conf.Logging); err != nil {
+			w.logger.Error("Keeping the current log levels", zap.Error(err))
+		} else {
+			w.config.Logging.Level = conf.Logging.Level
+			w.config.Logging.PackageLevels = conf.Logging.PackageLevels
+		}
+	}
+
+	if err := validation.SetPolicy(validation.NewPolicy(conf.Validation)); err != nil {
+		w.logger.Error("Keeping the current validation limits", zap.Error(err))
+	} else {
+		w.config.Validation = conf.Validation
+	}
+
+	w.rateLimiter.SetConfig(conf.RateLimit)
+	w.config.RateLimit = conf.RateLimit
+	w.config.Latency = conf.Latency
+
+	if moderator, err := w.newModerator(conf.Moderation); err != nil {
+		w.logger.Error("Keeping the current moderation rules", zap.Error(err))
+	} else {
+		w.moderator = moderator
+		w.config.Moderation = conf.Moderation
+	}
+
+	w.applyQueues(conf.Queues)
+
+	w.logger.Info("Applied reloaded configuration")
+}
+
+// applyQueues pauses and resumes queues that were disabled or enabled, and updates their retry policies.
+func (w *Worker) applyQueues(queues config.Queues) {
+	for queue, queueData := range w.config.Queues {
+		reloaded, ok := queues[queue]
+
+		if !ok {
+			continue
+		}
+
+		queueData.Retry = reloaded.Retry
+
+		if reloaded.Enabled != queueData.Enabled {
+			var err error
+
+			if reloaded.Enabled {
+				err = w.rabbitMQ.Resume(queue, queueData)
+			} else {
+				err = w.rabbitMQ.Pause(queue)
+			}
+
+			if err != nil {
+				w.logger.Error("Failed to change whether queue is consumed", zap.String("queue", queue), zap.Error(err))
+			} else {
+				queueData.Enabled = reloaded.Enabled
+				w.logger.Info("Changed whether queue is consumed", zap.String("queue", queue), zap.Bool("enabled", queueData.Enabled))
+			}
+		}
+
+		w.config.Queues[queue] = queueData
+	}
+}
+
+// restartRequired returns the settings, or groups of settings, that differ between current and
+// reloaded but cannot be changed without reconnecting or restarting.
+func restartRequired(current config.Config, reloaded config.Config) []string {
+	settings := make([]string, 0)
+
+	sections := []struct {
+		name              string
+		current, reloaded any
+	}{
+		{"RABBITMQ_*", current.RabbitMQ, reloaded.RabbitMQ},
+		{"POSTGRES_* and DATABASE_URL", current.Postgres, reloaded.Postgres},
+		{"SEARCH_LANGUAGE", current.Search, reloaded.Search},
+		{"METRICS_ADDRESS", current.Metrics, reloaded.Metrics},
+		{"DRY_RUN_*", current.DryRun, reloaded.DryRun},
+		{"LOG_FORMAT", current.Logging.Format, reloaded.Logging.Format},
+		{"LOG_SAMPLING_*", [2]int{current.Logging.SamplingInitial, current.Logging.SamplingThereafter}, [2]int{reloaded.Logging.SamplingInitial, reloaded.Logging.SamplingThereafter}},
+	}
+
+	for _, section := range sections {
+		if !reflect.DeepEqual(section.current, section.reloaded) {
+			settings = append(settings, section.name)
+		}
+	}
+
+	queues := make([]string, 0, len(current.Queues))
+
+	for queue := range current.Queues {
+		queues = append(queues, queue)
+	}
+
+	sort.Strings(queues)
+
+	for _, queue := range queues {
+		before, after := current.Queues[queue], reloaded.Queues[queue]
+		prefix := config.QueueKeyPrefix(queue)
+
+		if before.Exchange != after.Exchange {
+			settings = append(settings, prefix+"EXCHANGE")
+		}
+
+		if before.RoutingKey != after.RoutingKey {
+			settings = append(settings, prefix+"ROUTING_KEY")
+		}
+
+		if before.Prefetch != after.Prefetch {
+			settings = append(settings, prefix+"PREFETCH")
+		}
+	}
+
+	return settings
+}
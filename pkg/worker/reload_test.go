@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"kwekker-worker/pkg/config"
+	"kwekker-worker/pkg/moderation"
+	"kwekker-worker/pkg/validation"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// reloadTestConfig returns a valid configuration with one queue, which every call creates anew.
+func reloadTestConfig() config.Config {
+	return config.Config{
+		RabbitMQ: config.RabbitMQConfig{Host: "localhost"},
+		Postgres: config.PostgresConfig{Host: "localhost"},
+		Search:   config.SearchConfig{Language: "simple"},
+		Logging:  config.LoggingConfig{Level: "info", Format: "console"},
+		Validation: config.ValidationConfig{
+			TextMaxLength:        256,
+			UsernameMinLength:    3,
+			UsernameMaxLength:    15,
+			DisplayNameMaxLength: 30,
+			AvatarUrlMaxLength:   256,
+			TimestampMaxAgeDays:  30,
+		},
+		Queues: config.Queues{
+			"kwek.create": {Exchange: "kwek", RoutingKey: "kwek.create", Enabled: true},
+		},
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	prefix := config.QueueKeyPrefix("kwek.create")
+
+	tests := []struct {
+		name     string
+		change   func(conf *config.Config)
+		settings []string
+	}{
+		{
+			name:     "nothing",
+			change:   func(conf *config.Config) {},
+			settings: []string{},
+		},
+		{
+			name: "safe settings",
+			change: func(conf *config.Config) {
+				conf.Logging.Level = "debug"
+				conf.Validation.TextMaxLength = 5
+				conf.RateLimit.Enabled = true
+				conf.Latency.Retention = time.Hour
+				conf.Moderation.BlockedDomains = []string{"spam.example"}
+				conf.Queues["kwek.create"] = config.QueueData{Exchange: "kwek", RoutingKey: "kwek.create"}
+			},
+			settings: []string{},
+		},
+		{
+			name: "connections",
+			change: func(conf *config.Config) {
+				conf.RabbitMQ.Host = "rabbitmq"
+				conf.Postgres.URL = "postgres://postgres@postgres/kwekker"
+			},
+			settings: []string{"RABBITMQ_*", "POSTGRES_* and DATABASE_URL"},
+		},
+		{
+			name: "logging",
+			change: func(conf *config.Config) {
+				conf.Logging.Format = "json"
+				conf.Logging.SamplingInitial = 10
+			},
+			settings: []string{"LOG_FORMAT", "LOG_SAMPLING_*"},
+		},
+		{
+			name: "queue binding",
+			change: func(conf *config.Config) {
+				conf.Queues["kwek.create"] = config.QueueData{Exchange: "kweks", RoutingKey: "kwek.create", Prefetch: 10, Enabled: true}
+			},
+			settings: []string{prefix + "EXCHANGE", prefix + "PREFETCH"},
+		},
+	}
+
+	for _, test := range tests {
+		reloaded := reloadTestConfig()
+		test.change(&reloaded)
+
+		if settings := restartRequired(reloadTestConfig(), reloaded); !reflect.DeepEqual(settings, test.settings) {
+			t.Errorf("Changing %s should require a restart for %v, but requires one for %v", test.name, test.settings, settings)
+		}
+	}
+}
+
+func TestApplyConfigAppliesOnlySafeSettings(t *testing.T) {
+	previous := validation.CurrentPolicy()
+	t.Cleanup(func() { _ = validation.SetPolicy(previous) })
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	w := NewWorker(zap.New(core).Sugar(), reloadTestConfig())
+
+	if err := w.initializeModerator(); err != nil {
+		t.Fatalf("Failed to initialize moderator: %v", err)
+	}
+
+	reloaded := reloadTestConfig()
+	reloaded.Search.Language = "english"
+	reloaded.Validation.TextMaxLength = 5
+	reloaded.RateLimit = config.RateLimitConfig{Enabled: true, KweksPerMinute: 1, Burst: 1}
+	reloaded.Moderation.BlockedDomains = []string{"spam.example"}
+	reloaded.Latency.Retention = time.Hour
+
+	w.applyConfig(reloaded)
+
+	if w.config.Search.Language != "simple" {
+		t.Errorf("Search language should only change after a restart, but is %s", w.config.Search.Language)
+	}
+
+	warnings := logs.FilterMessageSnippet("Ignoring changed setting").FilterMessageSnippet("SEARCH_LANGUAGE")
+
+	if warnings.Len() != 1 {
+		t.Errorf("Changed search language should be logged once, but is logged %d times", warnings.Len())
+	}
+
+	if limit := validation.CurrentPolicy().TextMaxLength; limit != 5 {
+		t.Errorf("Text maximum length should be reloaded, but is %d", limit)
+	}
+
+	if w.config.Latency != reloaded.Latency {
+		t.Errorf("Latency retention should be reloaded, but is %s", w.config.Latency.Retention)
+	}
+
+	if w.config.RateLimit != reloaded.RateLimit {
+		t.Errorf("Rate limits should be reloaded, but are %+v", w.config.RateLimit)
+	}
+
+	if verdict := w.moderator.Moderate("Win at https://spam.example").Verdict; verdict != moderation.Reject {
+		t.Errorf("Reloaded moderation rules should reject the blocked domain, but the verdict is %s", verdict)
+	}
+}
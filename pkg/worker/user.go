@@ -2,44 +2,92 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	userproto "github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
+	"kwekker-worker/pkg/rabbitmq"
+	"kwekker-worker/pkg/validation"
+	"sort"
+	"strings"
 )
 
-func (w *Worker) handleCreateUser(createUser *userproto.CreateUser) {
-	w.logger.Debug("Handling create user request", "user", createUser)
+func (w *Worker) handleCreateUser(message rabbitmq.Message, createUser *userproto.CreateUser) error {
+	logger := w.messageLogger(message)
+	logger.Debug("Handling create user request", zap.Stringer("user", createUser))
 
-	_, err := w.dbconn.Exec(
-		context.Background(),
-		`INSERT INTO "Users" ("ProviderId", "Username", "Email", "DisplayName", "AvatarUrl")
-			 VALUES ($1, $2, $3, $4, $5)`,
+	ctx := context.Background()
+	tx, err := w.db.Begin(ctx)
+
+	if err != nil {
+		logger.Error("Failed to begin transaction", zap.Error(err))
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO "Users" ("ProviderId", "Username", "UsernameCanonical", "Email", "EmailCanonical", "DisplayName", "AvatarUrl")
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
 		createUser.GetUserId(),
 		createUser.GetUsername(),
+		validation.CanonicalUsername(createUser.GetUsername()),
 		createUser.GetEmail(),
+		validation.CanonicalEmail(createUser.GetEmail()),
 		createUser.GetDisplayName(),
 		createUser.GetAvatarUrl(),
 	)
 
+	if err = asConflict(err); errors.Is(err, errConflict) {
+		logger.Info("User conflicts with an existing user", zap.String("userId", createUser.GetUserId()), zap.Error(err))
+		return err
+	}
+
 	if err != nil {
-		w.logger.Error("Failed to insert user into database", zap.Error(err))
-		return
+		logger.Error("Failed to insert user into database", zap.Error(err))
+		return err
 	}
 
-	w.logger.Debug("Successfully inserted user into database")
+	err = w.writeAuditLog(ctx, tx, message, createUser.GetUserId(), map[string]fieldChange{
+		"Username":    {After: createUser.GetUsername()},
+		"Email":       {After: createUser.GetEmail()},
+		"DisplayName": {After: createUser.GetDisplayName()},
+		"AvatarUrl":   {After: createUser.GetAvatarUrl()},
+	})
+
+	if err != nil {
+		logger.Error("Failed to write audit log for user creation", zap.Error(err))
+		return err
+	}
+
+	w.recordLatency(ctx, tx, message)
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Error("Failed to commit user insertion", zap.Error(err))
+		return err
+	}
+
+	logger.Debug("Successfully inserted user into database")
+
+	return nil
 }
 
-func (w *Worker) handleUpdateUser(updateUser *userproto.UpdateUser) {
-	w.logger.Debug("Handling update user request", "user", updateUser)
+func (w *Worker) handleUpdateUser(message rabbitmq.Message, updateUser *userproto.UpdateUser) error {
+	logger := w.messageLogger(message)
+	logger.Debug("Handling update user request", zap.Stringer("user", updateUser))
 
 	updatedFields := make(map[string]string, 0)
 
 	if updateUser.GetUsername() != "" {
 		updatedFields["Username"] = updateUser.GetUsername()
+		updatedFields["UsernameCanonical"] = validation.CanonicalUsername(updateUser.GetUsername())
 	}
 
 	if updateUser.GetEmail() != "" {
 		updatedFields["Email"] = updateUser.GetEmail()
+		updatedFields["EmailCanonical"] = validation.CanonicalEmail(updateUser.GetEmail())
 	}
 
 	if updateUser.GetDisplayName() != "" {
@@ -51,49 +99,164 @@ func (w *Worker) handleUpdateUser(updateUser *userproto.UpdateUser) {
 	}
 
 	if len(updatedFields) == 0 {
-		w.logger.Debug("No fields to update")
-		return
+		logger.Debug("No fields to update")
+		return nil
+	}
+
+	// Iterate over the fields in a fixed order so the SELECT and UPDATE columns line up.
+	fields := make([]string, 0, len(updatedFields))
+
+	for field := range updatedFields {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	ctx := context.Background()
+	tx, err := w.db.Begin(ctx)
+
+	if err != nil {
+		logger.Error("Failed to begin transaction", zap.Error(err))
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	previousValues := make([]string, len(fields))
+	scanTargets := make([]any, len(fields))
+
+	for i := range previousValues {
+		scanTargets[i] = &previousValues[i]
+	}
+
+	err = tx.QueryRow(
+		ctx,
+		fmt.Sprintf(`SELECT "%s" FROM "Users" WHERE "ProviderId" = $1 FOR UPDATE`, strings.Join(fields, `", "`)),
+		updateUser.GetUserId(),
+	).Scan(scanTargets...)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		logger.Debug("No user to update")
+		return nil
+	}
+
+	if err != nil {
+		logger.Error("Failed to read user from database", zap.Error(err))
+		return err
 	}
 
 	query := `UPDATE "Users" SET `
 	values := []any{updateUser.GetUserId()}
 
 	i := 2
-	for field, value := range updatedFields {
+	for _, field := range fields {
 		query += fmt.Sprintf(`"%s" = $%d,`, field, i)
-		values = append(values, value)
+		values = append(values, updatedFields[field])
 		i++
 	}
 
 	query = fmt.Sprintf(`%s WHERE "ProviderId" = $1`, query[:len(query)-1])
 
-	_, err := w.dbconn.Exec(
-		context.Background(),
+	_, err = tx.Exec(
+		ctx,
 		query,
 		values...,
 	)
 
+	if err = asConflict(err); errors.Is(err, errConflict) {
+		logger.Info("User update conflicts with an existing user", zap.String("userId", updateUser.GetUserId()), zap.Error(err))
+		return err
+	}
+
+	if err != nil {
+		logger.Error("Failed to update user in database", zap.Error(err))
+		return err
+	}
+
+	changes := make(map[string]fieldChange, len(fields))
+
+	for i, field := range fields {
+		changes[field] = fieldChange{Before: previousValues[i], After: updatedFields[field]}
+	}
+
+	err = w.writeAuditLog(ctx, tx, message, updateUser.GetUserId(), changes)
+
 	if err != nil {
-		w.logger.Error("Failed to update kwek in database", zap.Error(err))
-		return
+		logger.Error("Failed to write audit log for user update", zap.Error(err))
+		return err
+	}
+
+	w.recordLatency(ctx, tx, message)
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Error("Failed to commit user update", zap.Error(err))
+		return err
 	}
 
-	w.logger.Debug("Successfully updated kwek in database")
+	logger.Debug("Successfully updated user in database")
+
+	return nil
 }
 
-func (w *Worker) handleDeleteUser(deleteUser *userproto.DeleteUser) {
-	w.logger.Debug("Handling delete user request", "user", deleteUser)
+func (w *Worker) handleDeleteUser(message rabbitmq.Message, deleteUser *userproto.DeleteUser) error {
+	logger := w.messageLogger(message)
+	logger.Debug("Handling delete user request", zap.Stringer("user", deleteUser))
+
+	ctx := context.Background()
+	tx, err := w.db.Begin(ctx)
+
+	if err != nil {
+		logger.Error("Failed to begin transaction", zap.Error(err))
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	// The kweks of the user would be deleted by the cascade of the user, which would leave them out of the audit log.
+	if err = w.deleteKweksOfUser(ctx, tx, message, deleteUser.GetUserId()); err != nil {
+		logger.Error("Failed to delete kweks of user in database", zap.Error(err))
+		return err
+	}
+
+	var previousUsername, previousEmail, previousDisplayName, previousAvatarUrl string
 
-	_, err := w.dbconn.Exec(
-		context.Background(),
-		`DELETE FROM "Users" WHERE "ProviderId" = $1`,
+	err = tx.QueryRow(
+		ctx,
+		`DELETE FROM "Users" WHERE "ProviderId" = $1
+			 RETURNING "Username", "Email", "DisplayName", "AvatarUrl"`,
 		deleteUser.GetUserId(),
-	)
+	).Scan(&previousUsername, &previousEmail, &previousDisplayName, &previousAvatarUrl)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		logger.Debug("No user to delete")
+		return nil
+	}
 
 	if err != nil {
-		w.logger.Error("Failed to delete user in database", zap.Error(err))
-		return
+		logger.Error("Failed to delete user in database", zap.Error(err))
+		return err
 	}
 
-	w.logger.Debug("Successfully deleted user in database")
+	err = w.writeAuditLog(ctx, tx, message, deleteUser.GetUserId(), map[string]fieldChange{
+		"Username":    {Before: previousUsername},
+		"Email":       {Before: previousEmail},
+		"DisplayName": {Before: previousDisplayName},
+		"AvatarUrl":   {Before: previousAvatarUrl},
+	})
+
+	if err != nil {
+		logger.Error("Failed to write audit log for user deletion", zap.Error(err))
+		return err
+	}
+
+	w.recordLatency(ctx, tx, message)
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Error("Failed to commit user deletion", zap.Error(err))
+		return err
+	}
+
+	logger.Debug("Successfully deleted user in database")
+
+	return nil
 }
@@ -2,58 +2,259 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
 	userproto "github.com/googolplex-s6/kwekker-protobufs/v3/user"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/zap"
-	"google.golang.org/protobuf/proto"
 	"kwekker-worker/pkg/config"
 	database "kwekker-worker/pkg/db"
+	"kwekker-worker/pkg/logging"
+	"kwekker-worker/pkg/metrics"
+	"kwekker-worker/pkg/moderation"
 	"kwekker-worker/pkg/rabbitmq"
+	"kwekker-worker/pkg/ratelimit"
+	"time"
 )
 
+var (
+	errRateLimited = errors.New("rate limit exceeded")
+	errConflict    = errors.New("conflicts with an existing entity")
+)
+
+// uniqueViolation is the SQLSTATE Postgres reports when a unique constraint or index is violated.
+const uniqueViolation = "23505"
+
+// asConflict turns a unique violation into errConflict, naming the constraint that was violated.
+// Any other error is returned unchanged.
+func asConflict(err error) error {
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return fmt.Errorf("%w (%s)", errConflict, pgErr.ConstraintName)
+	}
+
+	return err
+}
+
 type Worker struct {
 	logger *zap.SugaredLogger
-	config config.Config
-	dbconn *pgx.Conn
+	// root is the logger the worker was created with. The packages it uses name their loggers under it, rather
+	// than under that of the worker, so that LOG_PACKAGE_LEVELS sets their level by their own name.
+	root     *zap.SugaredLogger
+	config   config.Config
+	database *database.DB
+	dbconn   *pgx.Conn
+	// db is what handlers query: dbconn itself, or in dry-run mode a transaction that is rolled back.
+	db              database.Transactor
+	moderator       *moderation.Moderator
+	moderationRules []moderation.Rule
+	rateLimiter     *ratelimit.Limiter
+	rabbitMQ        *rabbitmq.RabbitMQWorker
+	// logLevels are the levels reloads change, when set with UseLogLevels.
+	logLevels *logging.Levels
 }
 
 func NewWorker(logger *zap.SugaredLogger, config config.Config) *Worker {
 	return &Worker{
-		logger: logger,
-		config: config,
+		logger:      logger.Named("worker"),
+		root:        logger,
+		config:      config,
+		database:    database.NewDB(logger, config.Postgres),
+		rabbitMQ:    rabbitmq.NewRabbitMQWorker(logger, config.RabbitMQ),
+		rateLimiter: ratelimit.NewLimiter(config.RateLimit),
 	}
 }
 
 func (w *Worker) Initialize() {
-	ch := make(chan proto.Message)
+	if err := w.initializeModerator(); err != nil {
+		w.logger.Fatal("Failed to initialize moderation rules", zap.Error(err))
+	}
+
+	if w.config.Metrics.Address != "" {
+		go metrics.Serve(w.root, w.config.Metrics.Address)
+	}
+
+	dryRun := w.config.DryRun
+
+	ch := make(chan rabbitmq.Message)
+
+	// done is closed when the worker stops, which stops the goroutines that only serve it.
+	done := make(chan struct{})
+	defer close(done)
+
+	if dryRun.Enabled {
+		w.rabbitMQ.EnableDryRun(dryRun.Mode)
+		go w.reportDryRun(dryRun.ReportInterval, done)
+
+		w.logger.Warn("Running in dry-run mode; nothing will be written", zap.String("mode", dryRun.Mode))
+	}
 
-	rabbitMQWorker := rabbitmq.NewRabbitMQWorker(w.logger, w.config.RabbitMQ)
-	go rabbitMQWorker.ListenToQueues(config.QueueList, ch)
+	go w.rabbitMQ.ListenToQueues(w.config.Queues.Enabled(), ch)
 
-	db := database.NewDB(w.logger, w.config.Postgres)
-	w.dbconn = db.Connect()
-	defer w.dbconn.Close(context.Background())
+	if !dryRun.Enabled || dryRun.ExecuteSql {
+		w.dbconn = w.database.Connect()
+		w.db = w.dbconn
+		defer w.dbconn.Close(context.Background())
+	}
+
+	reloads := make(chan config.Config)
+	go w.watchConfig(reloads)
+
+	// Latencies are pruned between messages, as the connection cannot be used by two goroutines at once. A
+	// dry run writes nothing, so the channel stays nil and latencies are never pruned.
+	var prunes <-chan time.Time
+
+	if !dryRun.Enabled {
+		pruneTicker := time.NewTicker(latencyPruneInterval)
+		defer pruneTicker.Stop()
+
+		prunes = pruneTicker.C
+	}
 
 	for {
 		select {
-		case data := <-ch:
-			switch data.(type) {
-			case *kwekproto.CreateKwek:
-				w.handleCreateKwek(data.(*kwekproto.CreateKwek))
-			case *kwekproto.UpdateKwek:
-				w.handleUpdateKwek(data.(*kwekproto.UpdateKwek))
-			case *kwekproto.DeleteKwek:
-				w.handleDeleteKwek(data.(*kwekproto.DeleteKwek))
-			case *userproto.CreateUser:
-				w.handleCreateUser(data.(*userproto.CreateUser))
-			case *userproto.UpdateUser:
-				w.handleUpdateUser(data.(*userproto.UpdateUser))
-			case *userproto.DeleteUser:
-				w.handleDeleteUser(data.(*userproto.DeleteUser))
-			default:
-				w.logger.Error("Unknown type received from channel")
+		case conf := <-reloads:
+			w.applyConfig(conf)
+		case <-prunes:
+			w.pruneLatencies()
+		case message := <-ch:
+			if dryRun.Enabled {
+				w.handleDryRun(message)
+			} else {
+				w.settle(message, w.handleWithRetries(message))
 			}
 		}
 	}
 }
+
+func (w *Worker) handleMessage(message rabbitmq.Message) error {
+	switch message.Protobuf.(type) {
+	case *kwekproto.CreateKwek:
+		return w.handleCreateKwek(message, message.Protobuf.(*kwekproto.CreateKwek))
+	case *kwekproto.UpdateKwek:
+		return w.handleUpdateKwek(message, message.Protobuf.(*kwekproto.UpdateKwek))
+	case *kwekproto.DeleteKwek:
+		return w.handleDeleteKwek(message, message.Protobuf.(*kwekproto.DeleteKwek))
+	case *userproto.CreateUser:
+		return w.handleCreateUser(message, message.Protobuf.(*userproto.CreateUser))
+	case *userproto.UpdateUser:
+		return w.handleUpdateUser(message, message.Protobuf.(*userproto.UpdateUser))
+	case *userproto.DeleteUser:
+		return w.handleDeleteUser(message, message.Protobuf.(*userproto.DeleteUser))
+	default:
+		w.messageLogger(message).Error("Unknown type received from channel")
+		return errors.New("unknown message type")
+	}
+}
+
+// messageLogger returns the logger for log lines about message, which carry the fields that identify it.
+func (w *Worker) messageLogger(message rabbitmq.Message) *zap.SugaredLogger {
+	return w.logger.With(logging.MessageFields(
+		message.Queue,
+		message.MessageId,
+		logging.EntityId(message.Protobuf),
+		message.TraceId,
+	)...)
+}
+
+// handleWithRetries handles message, and retries failures that may be transient according to the retry
+// policy of the queue the message came from.
+func (w *Worker) handleWithRetries(message rabbitmq.Message) error {
+	retry := w.config.Queues[message.Queue].Retry
+	err := w.handleMessage(message)
+
+	for attempt := 1; err != nil && attempt < retry.MaxAttempts; attempt++ {
+		if rejectionReason(err) != rabbitmq.ReasonProcessingFailed {
+			break
+		}
+
+		w.messageLogger(message).Warn("Retrying message", zap.Int("attempt", attempt+1), zap.Error(err))
+		time.Sleep(retry.Delay)
+
+		err = w.handleMessage(message)
+	}
+
+	return err
+}
+
+// settle acknowledges a handled message, or moves it to the dead-letter queue when handling failed.
+func (w *Worker) settle(message rabbitmq.Message, err error) {
+	logger := w.messageLogger(message)
+
+	if err == nil {
+		if err = message.Ack(); err != nil {
+			logger.Error("Failed to acknowledge message", zap.Error(err))
+		}
+
+		return
+	}
+
+	reason := rejectionReason(err)
+
+	logger.Warn("Moving message to dead-letter queue", zap.String("reason", reason), zap.Error(err))
+
+	if err = message.Reject(reason, err); err != nil {
+		logger.Error("Failed to dead-letter message", zap.Error(err))
+	}
+}
+
+// latencyPruneInterval is how often latencies older than LATENCY_RETENTION are deleted.
+const latencyPruneInterval = time.Hour
+
+// pruneLatencies deletes the latencies that are older than the configured retention. Failing to delete them
+// is only logged, as they are tried again at the next interval.
+func (w *Worker) pruneLatencies() {
+	if w.config.Latency.Retention <= 0 {
+		return
+	}
+
+	deleted, err := database.DeleteLatenciesBefore(context.Background(), w.dbconn, time.Now().Add(-w.config.Latency.Retention))
+
+	if err != nil {
+		w.logger.Warn("Failed to delete old message latencies", zap.Error(err))
+		return
+	}
+
+	w.logger.Debug("Deleted old message latencies", zap.Int64("deleted", deleted))
+}
+
+// recordLatency records how long it took from publishing message until its handler committed the change it asked
+// for. Handlers call it in their transaction right before committing it, so that messages that change nothing, such
+// as kweks rejected by moderation, are not measured. It is recorded in a savepoint, so failing to record it does not
+// affect the change and is only logged.
+func (w *Worker) recordLatency(ctx context.Context, tx pgx.Tx, message rabbitmq.Message) {
+	if message.PublishedAt.IsZero() {
+		return
+	}
+
+	savepoint, err := tx.Begin(ctx)
+
+	if err == nil {
+		err = database.RecordLatency(ctx, savepoint, message.Queue, message.MessageId, message.PublishedAt)
+
+		if err == nil {
+			err = savepoint.Commit(ctx)
+		} else {
+			savepoint.Rollback(ctx)
+		}
+	}
+
+	if err != nil {
+		w.messageLogger(message).Warn("Failed to record message latency", zap.Error(err))
+	}
+}
+
+// rejectionReason returns the reason a message is dead-lettered for when its handler fails with err.
+func rejectionReason(err error) string {
+	switch {
+	case errors.Is(err, errRateLimited):
+		return rabbitmq.ReasonRateLimited
+	case errors.Is(err, errConflict):
+		return rabbitmq.ReasonConflict
+	default:
+		return rabbitmq.ReasonProcessingFailed
+	}
+}
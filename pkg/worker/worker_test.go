@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	kwekproto "github.com/googolplex-s6/kwekker-protobufs/v3/kwek"
+	userproto "github.com/googolplex-s6/kwekker-protobufs/v3/user"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"kwekker-worker/pkg/config"
+	database "kwekker-worker/pkg/db"
+	"kwekker-worker/pkg/db/dbtest"
+	"kwekker-worker/pkg/rabbitmq"
+	"testing"
+	"time"
+)
+
+// connectTestWorker creates a worker that handles messages against a schema of its own in the test database.
+func connectTestWorker(t *testing.T, conf config.Config) *Worker {
+	conn := dbtest.Connect(t)
+
+	if conf.Search.Language == "" {
+		conf.Search.Language = "simple"
+	}
+
+	w := NewWorker(zap.NewNop().Sugar(), conf)
+	w.dbconn = conn
+	w.db = conn
+
+	if err := w.initializeModerator(); err != nil {
+		t.Fatalf("Failed to initialize moderator: %v", err)
+	}
+
+	return w
+}
+
+// testMessage returns a message as the worker receives it from queue, published just now.
+func testMessage(queue string, protobuf proto.Message) rabbitmq.Message {
+	return rabbitmq.Message{
+		Protobuf:    protobuf,
+		Queue:       queue,
+		MessageId:   uuid.NewString(),
+		PublishedAt: time.Now(),
+	}
+}
+
+// handle handles message the way the worker does, and fails the test if handling it fails.
+func handle(t *testing.T, w *Worker, message rabbitmq.Message) {
+	t.Helper()
+
+	if err := w.handleWithRetries(message); err != nil {
+		t.Fatalf("Message on %s should be handled, but is not: %v", message.Queue, err)
+	}
+}
+
+// countRows counts the rows of table that match where, which uses the arguments in args.
+func countRows(t *testing.T, w *Worker, table string, where string, args ...any) int {
+	t.Helper()
+
+	var count int
+
+	err := w.dbconn.QueryRow(context.Background(), fmt.Sprintf(`SELECT count(*) FROM %q WHERE %s`, table, where), args...).Scan(&count)
+
+	if err != nil {
+		t.Fatalf("Failed to count rows of %s: %v", table, err)
+	}
+
+	return count
+}
+
+func TestLatencyIsRecordedOnlyForCommittedChanges(t *testing.T) {
+	w := connectTestWorker(t, config.Config{
+		Moderation: config.ModerationConfig{BlockedDomains: []string{"spam.example"}},
+	})
+
+	createUser := testMessage("user.create", &userproto.CreateUser{
+		UserId:      "provider|1",
+		Username:    "kwekker_fan",
+		Email:       "fan@example.com",
+		DisplayName: "Fan",
+		CreatedAt:   timestamppb.Now(),
+	})
+	handle(t, w, createUser)
+
+	if count := countRows(t, w, "MessageLatencies", `"MessageId" = $1`, createUser.MessageId); count != 1 {
+		t.Errorf("Latency of the created user should be recorded once, but is recorded %d times", count)
+	}
+
+	unchanged := []rabbitmq.Message{
+		testMessage("kwek.create", &kwekproto.CreateKwek{
+			KwekGuid: uuid.NewString(),
+			Text:     "Free stuff at https://spam.example/win",
+			UserId:   "provider|1",
+			PostedAt: timestamppb.Now(),
+		}),
+		testMessage("kwek.update", &kwekproto.UpdateKwek{KwekGuid: uuid.NewString(), Text: "Edited"}),
+		testMessage("kwek.delete", &kwekproto.DeleteKwek{KwekGuid: uuid.NewString()}),
+		testMessage("user.delete", &userproto.DeleteUser{UserId: "provider|2"}),
+	}
+
+	for _, message := range unchanged {
+		handle(t, w, message)
+
+		if count := countRows(t, w, "MessageLatencies", `"MessageId" = $1`, message.MessageId); count != 0 {
+			t.Errorf("Latency of %s that changed nothing should not be recorded, but is", message.Queue)
+		}
+	}
+}
+
+func TestAsConflictNamesTheViolatedConstraint(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		conflict string
+	}{
+		{
+			name:     "unique violation",
+			err:      &pgconn.PgError{Code: "23505", ConstraintName: "UX_Users_UsernameCanonical"},
+			conflict: "conflicts with an existing entity (UX_Users_UsernameCanonical)",
+		},
+		{
+			name: "foreign key violation",
+			err:  &pgconn.PgError{Code: "23503", ConstraintName: "FK_Kweks_Users_UserId"},
+		},
+		{
+			name: "other error",
+			err:  errors.New("connection reset"),
+		},
+		{
+			name: "no error",
+		},
+	}
+
+	for _, test := range tests {
+		err := asConflict(test.err)
+
+		if test.conflict == "" {
+			if err != test.err {
+				t.Errorf("%s should be returned unchanged, but is %v", test.name, err)
+			}
+
+			continue
+		}
+
+		if !errors.Is(err, errConflict) || err.Error() != test.conflict {
+			t.Errorf("%s should be %q, but is %v", test.name, test.conflict, err)
+		}
+	}
+}
+
+func TestPruneLatenciesOnlyDeletesThemAfterTheRetention(t *testing.T) {
+	w := connectTestWorker(t, config.Config{})
+
+	if err := database.RecordLatency(context.Background(), w.dbconn, "kwek.create", "", time.Now()); err != nil {
+		t.Fatalf("Latency should be recorded, but is not: %v", err)
+	}
+
+	for _, retention := range []time.Duration{0, time.Hour} {
+		w.config.Latency.Retention = retention
+		w.pruneLatencies()
+
+		if count := countRows(t, w, "MessageLatencies", "true"); count != 1 {
+			t.Errorf("Latency should be kept with a retention of %s, but is not", retention)
+		}
+	}
+
+	w.config.Latency.Retention = time.Nanosecond
+	w.pruneLatencies()
+
+	if count := countRows(t, w, "MessageLatencies", "true"); count != 0 {
+		t.Errorf("Latency older than the retention should be deleted, but is kept")
+	}
+}